@@ -4,16 +4,21 @@ import (
 	"archive/zip"
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"hash/crc32"
 	"io"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"sync"
+	"time"
 	"unicode/utf8"
 
+	"github.com/klauspost/compress/flate"
 	"github.com/ybirader/pzip/pool"
 )
 
@@ -21,6 +26,7 @@ const (
 	defaultCompression = -1
 	zipVersion20       = 20
 	sequentialWrites   = 1
+	reorderWindow      = 2 // files per compression routine held when writing entries in order
 )
 
 const bufferSize = 32 * 1024
@@ -32,38 +38,110 @@ var bufferPool = sync.Pool{
 }
 
 type archiver struct {
-	xArchive            *os.File
+	xArchive            io.Writer
 	concurrency         int
 	w                   *zip.Writer
 	fileProcessPool     pool.WorkerPool[pool.File]
 	fileWriterPool      pool.WorkerPool[pool.File]
 	chroot              string
 	absoluteArchivePath string
+	blockSize           int
+	parallelThreshold   int64
+	blockCompressors    sync.Pool
+	blockSlots          chan struct{} // limits the blocks being compressed or written across files
+	ctx                 context.Context
+	method              uint16
+	level               int
+	storedExtensions    map[string]bool
+	sampleCompressed    bool
+	storeSymlinks       bool
+	filter              filter
+	ignorer             *ignorer
+	fsys                fs.FS
+	readers             []*pool.File
+	updater             *updater
+	encrypter           encrypter
+	split               *SplitWriter
+	ordered             bool
+	reproducible        bool
+	sourceDate          time.Time
+	storeFileTimes      bool
+	comment             string
+	commented           bool // whether comment was set, rather than kept from an updated archive
+	entryComment        func(name string) string
+	progress            *progressTracker
+	report              Report
+	reportEntries       bool
+	started             time.Time
+	dryRun              func(name string, info fs.FileInfo)
+	prefix              string
+	rootNames           map[string]string // destinations of roots, by their cleaned paths
+	rootName            string            // destination of the root being archived, if rootMapped
+	rootMapped          bool
+	renameFunc          func(path string) (name string, skip bool)
+	collected           []collectedFile
+	reorder             *reorderBuffer
 }
 
-// NewArchiver returns a new pzip archiver. The archiver can be configured by passing in a number of options.
+// NewArchiver returns a new pzip archiver, writing the archive to archive. When archive is a file, such as an *os.File,
+// the file itself is never archived. The archiver can be configured by passing in a number of options.
 // Available options include ArchiverConcurrency(n int). It returns an error if the archiver can't be created
 // Close() should be called on the returned archiver when done
-func NewArchiver(archive *os.File, options ...archiverOption) (*archiver, error) {
+func NewArchiver(archive io.Writer, options ...archiverOption) (*archiver, error) {
 	a := &archiver{
-		xArchive:    archive,
-		w:           zip.NewWriter(archive),
-		concurrency: runtime.GOMAXPROCS(0),
+		xArchive:          archive,
+		w:                 zip.NewWriter(archive),
+		concurrency:       runtime.GOMAXPROCS(0),
+		blockSize:         defaultBlockSize,
+		parallelThreshold: defaultParallelThreshold,
+		method:            zip.Deflate,
+		level:             defaultCompression,
+		ctx:               context.Background(),
+	}
+	a.blockCompressors.New = func() any {
+		compressor, _ := flate.NewWriter(nil, a.level)
+		return compressor
+	}
+
+	if split, ok := archive.(*SplitWriter); ok {
+		a.split = split
 	}
 
 	var err error
-	a.absoluteArchivePath, err = filepath.Abs(archive.Name())
-	if err != nil {
-		return nil, fmt.Errorf("absolute archive path %q: %w", archive.Name(), err)
+	if file, ok := archive.(interface{ Name() string }); ok {
+		a.absoluteArchivePath, err = filepath.Abs(file.Name())
+		if err != nil {
+			return nil, fmt.Errorf("absolute archive path %q: %w", file.Name(), err)
+		}
 	}
 
 	fileProcessExecutor := func(file *pool.File) error {
+		start := time.Now()
 		err := a.compress(file)
+		file.Elapsed = time.Since(start)
 		if err != nil {
+			if a.reorder != nil {
+				a.reorder.discard(file.Sequence)
+			}
 			return fmt.Errorf("compress file %q: %w", file.Path, err)
 		}
 
-		a.fileWriterPool.Enqueue(file)
+		a.progress.update(func(progress *Progress) {
+			progress.FilesCompressed++
+			if file.Source != nil {
+				// unchanged files aren't read, but their entries count as read and compressed
+				progress.BytesRead += int64(file.Source.UncompressedSize64)
+				progress.BytesCompressed += int64(file.Source.CompressedSize64)
+			} else {
+				progress.BytesCompressed += file.Written()
+			}
+		})
+
+		if a.reorder != nil {
+			a.reorder.add(file)
+		} else {
+			a.fileWriterPool.Enqueue(file)
+		}
 
 		return nil
 	}
@@ -96,6 +174,33 @@ func NewArchiver(archive *os.File, options ...archiverOption) (*archiver, error)
 		}
 	}
 
+	a.blockSlots = make(chan struct{}, a.concurrency)
+
+	// updated archives keep their comment unless it's replaced
+	if !a.commented && a.updater != nil {
+		a.comment = a.updater.archive.Comment
+	}
+	if a.updater != nil {
+		a.updater.encrypter = a.encrypter
+	}
+	if err = a.w.SetComment(a.comment); err != nil {
+		return nil, fmt.Errorf("set comment: %w", err)
+	}
+
+	if a.reproducible && a.encrypter != nil {
+		return nil, errors.New("encrypted archives can't be reproducible, as encryption uses random salts")
+	}
+	if a.reproducible && a.updater != nil {
+		a.updater.close()
+		return nil, errors.New("updated archives can't be reproducible, as they keep entries of the existing archive")
+	}
+
+	if a.ordered || a.reproducible {
+		a.reorder = newReorderBuffer(reorderWindow*a.concurrency, func(file *pool.File) {
+			a.fileWriterPool.Enqueue(file)
+		})
+	}
+
 	return a, nil
 }
 
@@ -103,15 +208,31 @@ func NewArchiver(archive *os.File, options ...archiverOption) (*archiver, error)
 // the corresponding archive registered with the archiver. Archiving is canceled when the
 // associated ctx is canceled. The first error that arises during archiving is returned.
 func (a *archiver) Archive(ctx context.Context, filePaths []string) error {
+	if a.started.IsZero() {
+		a.started = time.Now()
+	}
+	a.ctx = ctx
+
 	a.fileProcessPool.Start(ctx)
 	a.fileWriterPool.Start(ctx)
 
+	for _, file := range a.readers {
+		if a.reproducible {
+			a.collect(file, "")
+		} else {
+			a.enqueueFile(file)
+		}
+	}
+	a.readers = nil
+
 	for _, path := range filePaths {
-		info, err := os.Lstat(path)
+		info, err := a.stat(path)
 		if err != nil {
-			return fmt.Errorf("lstat %q: %w", path, err)
+			return err
 		}
 
+		a.rootName, a.rootMapped = a.rootNames[filepath.Clean(path)]
+
 		if info.IsDir() {
 			if err = a.archiveDir(path); err != nil {
 				return fmt.Errorf("archive dir %q: %w", path, err)
@@ -127,6 +248,16 @@ func (a *archiver) Archive(ctx context.Context, filePaths []string) error {
 		}
 	}
 
+	if a.reproducible {
+		if err := a.archiveCollected(); err != nil {
+			return err
+		}
+	}
+
+	a.progress.update(func(progress *Progress) {
+		progress.Counted = true
+	})
+
 	if err := a.fileProcessPool.Close(); err != nil {
 		return fmt.Errorf("close file process pool: %w", err)
 	}
@@ -135,10 +266,92 @@ func (a *archiver) Archive(ctx context.Context, filePaths []string) error {
 		return fmt.Errorf("close file writer pool: %w", err)
 	}
 
+	a.progress.finish()
 	return nil
 }
 
+// AddReader adds an entry to the archive, with contents read from r and named and described by header, such as
+// a generated manifest. The entry is compressed concurrently with the files archived by the next call to Archive
+// or ArchiveFS, so r must remain readable until then. Archive can be called without any files to only archive
+// the added entries. Directory entries are added by giving a header with a name ending in a slash.
+func (a *archiver) AddReader(header *zip.FileHeader, r io.Reader) error {
+	if header.Name == "" {
+		return errors.New("header has no name")
+	}
+
+	a.readers = append(a.readers, pool.NewReaderFile(header, r))
+	return nil
+}
+
+// ArchiveFS compresses and stores (archives) the files at the provided roots of fsys to the corresponding
+// archive registered with the archiver, in the same way as Archive. Roots are paths within fsys; "." archives
+// all of its files. Archiving is canceled when the associated ctx is canceled. The first error that arises
+// during archiving is returned.
+func (a *archiver) ArchiveFS(ctx context.Context, fsys fs.FS, roots ...string) error {
+	a.fsys = fsys
+	defer func() {
+		a.fsys = nil
+	}()
+
+	return a.Archive(ctx, roots)
+}
+
+// stat returns the file info of path, following symbolic links unless storing them as links.
+func (a *archiver) stat(path string) (fs.FileInfo, error) {
+	if a.fsys != nil {
+		info, err := fs.Stat(a.fsys, path)
+		if err != nil {
+			return nil, fmt.Errorf("stat %q: %w", path, err)
+		}
+		return info, nil
+	}
+
+	if !a.storeSymlinks {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("stat %q: %w", path, err)
+		}
+		return info, nil
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, fmt.Errorf("lstat %q: %w", path, err)
+	}
+	return info, nil
+}
+
+// Close finishes writing the archive. When updating an existing archive, the entries of files that weren't
+// archived are copied from the existing archive first, unless the archive is synced. The volumes of a split
+// archive are left open, to be closed by the SplitWriter.
 func (a *archiver) Close() error {
+	if !a.started.IsZero() {
+		a.report.Duration = time.Since(a.started)
+	}
+
+	if a.dryRun != nil {
+		// nothing is written during a dry run, not even an empty archive
+		if a.updater != nil {
+			return a.updater.close()
+		}
+		return nil
+	}
+
+	if a.updater != nil {
+		defer a.updater.close()
+
+		for _, entry := range a.updater.remaining() {
+			if err := copyEntry(a.w, entry); err != nil {
+				return fmt.Errorf("copy existing entry %q: %w", entry.Name, err)
+			}
+			a.report.add(&entry.FileHeader, 0, false, true, a.reportEntries)
+		}
+	}
+
+	if a.split != nil {
+		return a.closeSplit()
+	}
+
 	if err := a.w.Close(); err != nil {
 		return fmt.Errorf("close zip writer: %w", err)
 	}
@@ -146,6 +359,31 @@ func (a *archiver) Close() error {
 	return nil
 }
 
+// Report returns the report of the entries written so far, listing each entry if the archiver was created with
+// the ArchiverReport option. Entries kept from an updated archive are reported once the archiver is closed.
+func (a *archiver) Report() Report {
+	return a.report
+}
+
+// closeSplit finishes writing a split archive. The central directory written by the zip writer locates entries
+// by their offsets within the archive, so it's captured and written with the volumes on which entries start.
+func (a *archiver) closeSplit() error {
+	if err := a.w.Flush(); err != nil {
+		return fmt.Errorf("flush zip writer: %w", err)
+	}
+
+	a.split.captureDirectory()
+	if err := a.w.Close(); err != nil {
+		return fmt.Errorf("close zip writer: %w", err)
+	}
+
+	if err := a.split.writeDirectory(); err != nil {
+		return fmt.Errorf("write central directory: %w", err)
+	}
+
+	return nil
+}
+
 func (a *archiver) archiveDir(root string) error {
 	if err := a.changeRoot(root); err != nil {
 		return fmt.Errorf("change root to %q: %w", root, err)
@@ -158,18 +396,96 @@ func (a *archiver) archiveDir(root string) error {
 	return nil
 }
 
+// open opens the file at path, from the archived file system if there is one.
+func (a *archiver) open(path string) (fs.File, error) {
+	if a.fsys != nil {
+		return a.fsys.Open(filepath.ToSlash(path))
+	}
+
+	return os.Open(path)
+}
+
+// openFile opens the contents of file, which are read from its reader if it has one.
+func (a *archiver) openFile(file *pool.File) (io.ReadCloser, error) {
+	if file.Reader != nil {
+		return io.NopCloser(file.Reader), nil
+	}
+
+	return a.open(file.Path)
+}
+
 // archiveFile enqueues file for archiving if it doesn't match
-// our output file.
+// our output file and isn't filtered out, once it's renamed. Files of
+// reproducible archives are collected to be archived in order instead.
 func (a *archiver) archiveFile(file *pool.File) {
-	if file.Path == a.absoluteArchivePath {
-		// Don't archive the output file.
+	if file.Path == a.absoluteArchivePath || (a.updater != nil && file.Path == a.updater.path) ||
+		(a.split != nil && a.split.isVolume(file.Path)) {
+		// Don't archive the output file, the archive being updated, or the volumes of a split archive.
+		return
+	}
+
+	isDir := file.Info.IsDir()
+	if a.filter.excluded(file.Header.Name, isDir) || !a.filter.included(file.Header.Name, isDir) {
+		pool.FilePool.Put(file)
+		return
+	}
+
+	if a.rename(file) {
+		pool.FilePool.Put(file)
+		return
+	}
+
+	if a.reproducible {
+		a.collect(file, a.chroot)
 		return
 	}
 
+	a.enqueueFile(file)
+}
+
+// enqueueFile enqueues file for compression. When updating, the existing entry of file is claimed, so that
+// it's copied rather than compressed if file is unchanged. During a dry run, file is listed instead.
+func (a *archiver) enqueueFile(file *pool.File) {
+	if a.dryRun != nil {
+		a.dryRun(entryName(file), file.Info)
+		pool.FilePool.Put(file)
+		return
+	}
+
+	if a.updater != nil {
+		file.Source = a.updater.claim(file)
+	}
+
+	if a.reorder != nil {
+		a.reorder.sequence(file)
+	}
+
+	a.progress.update(func(progress *Progress) {
+		progress.TotalFiles++
+		if file.Info.Mode().IsRegular() {
+			progress.TotalBytes += file.Info.Size()
+		}
+	})
+
 	a.fileProcessPool.Enqueue(file)
 }
 
+// entryName returns the name of the entry of file, which ends with a slash for directories.
+func entryName(file *pool.File) string {
+	name := file.Header.Name
+	if file.Info.IsDir() && !strings.HasSuffix(name, "/") {
+		name += "/"
+	}
+
+	return name
+}
+
 func (a *archiver) changeRoot(root string) error {
+	if a.fsys != nil {
+		a.chroot = path.Clean(root)
+		return nil
+	}
+
 	absRoot, err := filepath.Abs(root)
 	if err != nil {
 		return fmt.Errorf("get absolute path of %q: %w", root, err)
@@ -180,26 +496,174 @@ func (a *archiver) changeRoot(root string) error {
 }
 
 func (a *archiver) walkDir() error {
-	if err := filepath.Walk(a.chroot, func(path string, info fs.FileInfo, err error) error {
+	if a.fsys != nil {
+		return a.walkFS()
+	}
+
+	followed := make(map[string]bool)
+	if !a.storeSymlinks {
+		resolvedRoot, err := filepath.EvalSymlinks(a.chroot)
+		if err != nil {
+			return fmt.Errorf("resolve %q: %w", a.chroot, err)
+		}
+		followed[resolvedRoot] = true
+	}
+
+	if a.ignorer != nil {
+		a.ignorer.reset(a.chroot)
+	}
+
+	if err := a.walk(a.chroot, followed); err != nil {
+		return fmt.Errorf("walk directory %q: %w", a.chroot, err)
+	}
+
+	return nil
+}
+
+// walk enqueues the files of the directory tree at root for archiving. When following symbolic links,
+// links to directories are walked as well. followed holds the resolved directories that are being walked,
+// so that links which form a cycle can be detected.
+func (a *archiver) walk(root string, followed map[string]bool) error {
+	if !a.storeSymlinks {
+		// a trailing separator makes filepath.Walk resolve root if it's a symbolic link
+		root += string(filepath.Separator)
+	}
+
+	return filepath.Walk(root, func(path string, info fs.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
-		file, err := pool.NewFile(path, info, a.chroot)
+		isSymlink := info.Mode()&fs.ModeSymlink != 0
+		if !a.storeSymlinks && isSymlink {
+			if info, err = os.Stat(path); err != nil {
+				return fmt.Errorf("follow symlink %q: %w", path, err)
+			}
+		}
+
+		if ignored, err := a.ignore(path, info, isSymlink); ignored || err != nil {
+			return err
+		}
+
+		if isSymlink && info.IsDir() {
+			return a.walkSymlinkedDir(path, followed)
+		}
+
+		return a.enqueue(path, info)
+	})
+}
+
+// walkFS enqueues the files of the directory tree at the root of the archived file system.
+// Symbolic links are archived as the files they refer to, while links to directories are skipped.
+func (a *archiver) walkFS() error {
+	if a.ignorer != nil {
+		a.ignorer.reset(a.chroot)
+	}
+
+	err := fs.WalkDir(a.fsys, a.chroot, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
-			return fmt.Errorf("new file %q: %w", path, err)
+			return err
 		}
-		a.archiveFile(file)
 
-		return nil
-	}); err != nil {
+		info, err := d.Info()
+		if err != nil {
+			return fmt.Errorf("file info of %q: %w", path, err)
+		}
+
+		isSymlink := info.Mode()&fs.ModeSymlink != 0
+		if isSymlink {
+			if info, err = fs.Stat(a.fsys, path); err != nil {
+				return fmt.Errorf("follow symlink %q: %w", path, err)
+			}
+			if info.IsDir() {
+				return nil
+			}
+		}
+
+		if ignored, err := a.ignore(path, info, isSymlink); ignored || err != nil {
+			return err
+		}
+
+		if path == "." {
+			// the root of the file system has no name of its own
+			return nil
+		}
+
+		return a.enqueue(path, info)
+	})
+	if err != nil {
 		return fmt.Errorf("walk directory %q: %w", a.chroot, err)
 	}
 
 	return nil
 }
 
+// ignore reports whether the file at path is ignored by ignore files, in which case the returned
+// error is filepath.SkipDir for directories, so they aren't walked. The ignore files of directories
+// that aren't ignored are loaded.
+func (a *archiver) ignore(path string, info fs.FileInfo, isSymlink bool) (bool, error) {
+	if a.ignorer == nil {
+		return false, nil
+	}
+
+	isDir := info.IsDir() && !isSymlink
+	if a.ignorer.ignored(path, info.IsDir()) {
+		if isDir {
+			return true, filepath.SkipDir
+		}
+		return true, nil
+	}
+
+	if isDir {
+		if err := a.ignorer.load(path); err != nil {
+			return false, fmt.Errorf("load ignore files of %q: %w", path, err)
+		}
+	}
+
+	return false, nil
+}
+
+// enqueue archives the file at path, found while walking a directory. It returns filepath.SkipDir for
+// excluded directories.
+func (a *archiver) enqueue(path string, info fs.FileInfo) error {
+	file, err := pool.NewFile(path, info, a.chroot)
+	if err != nil {
+		return fmt.Errorf("new file %q: %w", path, err)
+	}
+
+	if info.IsDir() && a.filter.excluded(file.Header.Name, true) {
+		// prune excluded directories rather than walking their contents
+		pool.FilePool.Put(file)
+		return filepath.SkipDir
+	}
+
+	a.archiveFile(file)
+
+	return nil
+}
+
+func (a *archiver) walkSymlinkedDir(path string, followed map[string]bool) error {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return fmt.Errorf("resolve %q: %w", path, err)
+	}
+
+	if followed[target] {
+		return fmt.Errorf("symlink %q to %q forms a cycle", path, target)
+	}
+
+	followed[target] = true
+	defer delete(followed, target)
+
+	return a.walk(path, followed)
+}
+
 func (a *archiver) compress(file *pool.File) error {
+	if file.Source != nil {
+		// unchanged files are copied from the archive being updated
+		return nil
+	}
+
 	if file.Info.IsDir() {
 		if err := a.populateHeader(file); err != nil {
 			return fmt.Errorf("populate header for %q: %w", file.Path, err)
@@ -207,46 +671,148 @@ func (a *archiver) compress(file *pool.File) error {
 		return nil
 	}
 
-	hasher := crc32.NewIEEE()
+	if file.Reader == nil && file.Info.Mode()&fs.ModeSymlink != 0 {
+		if err := a.compressSymlink(file); err != nil {
+			return fmt.Errorf("compress symlink %q: %w", file.Path, err)
+		}
+		return nil
+	}
+
+	method, err := a.methodFor(file)
+	if err != nil {
+		return fmt.Errorf("method for %q: %w", file.Path, err)
+	}
 
-	if err := a.copy(io.MultiWriter(file.Compressor, hasher), file); err != nil {
-		return fmt.Errorf("copy %q: %w", file.Path, err)
+	crc, size, err := a.compressWith(file, method)
+	if err != nil {
+		return err
 	}
 
-	if err := file.Compressor.Close(); err != nil {
-		return fmt.Errorf("close compressor for %q: %w", file.Path, err)
+	file.Header.Method = method
+	file.Header.UncompressedSize64 = uint64(size)
+	if err = a.populateHeader(file); err != nil {
+		return fmt.Errorf("populate header for %q: %w", file.Path, err)
+	}
+
+	file.Header.CRC32 = crc
+
+	if a.encrypter != nil {
+		if err = a.encrypter.encrypt(file); err != nil {
+			return fmt.Errorf("encrypt %q: %w", file.Path, err)
+		}
+		file.Header.CompressedSize64 = uint64(file.Written())
 	}
 
-	if err := a.populateHeader(file); err != nil {
+	return nil
+}
+
+// compressSymlink stores the target of the symbolic link file as its contents, as done by Info-ZIP.
+func (a *archiver) compressSymlink(file *pool.File) error {
+	target, err := os.Readlink(file.Path)
+	if err != nil {
+		return fmt.Errorf("read link %q: %w", file.Path, err)
+	}
+
+	if _, err = io.WriteString(file, target); err != nil {
+		return fmt.Errorf("write link target of %q: %w", file.Path, err)
+	}
+
+	file.Header.Method = zip.Store
+	file.Header.UncompressedSize64 = uint64(len(target))
+	if err = a.populateHeader(file); err != nil {
 		return fmt.Errorf("populate header for %q: %w", file.Path, err)
 	}
 
-	file.Header.CRC32 = hasher.Sum32()
+	file.Header.CRC32 = crc32.ChecksumIEEE([]byte(target))
 	return nil
 }
 
-func (a *archiver) copy(w io.Writer, file *pool.File) error {
-	f, err := os.Open(file.Path)
+// compressWith compresses file using method. It returns the CRC-32 and size of the uncompressed contents of file.
+func (a *archiver) compressWith(file *pool.File, method uint16) (uint32, int64, error) {
+	if a.encrypter != nil {
+		if err := reserveHeader(file, a.encrypter.headerSize()); err != nil {
+			return 0, 0, fmt.Errorf("reserve header of %q: %w", file.Path, err)
+		}
+	}
+
+	if method == zip.Deflate && a.compressesInBlocks(file) {
+		crc, size, err := a.compressBlocks(file)
+		if err != nil {
+			return 0, 0, fmt.Errorf("compress blocks of %q: %w", file.Path, err)
+		}
+		return crc, size, nil
+	}
+
+	hasher := crc32.NewIEEE()
+
+	if method == zip.Store {
+		size, err := a.copy(io.MultiWriter(file, hasher), file)
+		if err != nil {
+			return 0, 0, fmt.Errorf("copy %q: %w", file.Path, err)
+		}
+		return hasher.Sum32(), size, nil
+	}
+
+	if err := file.SetCompression(method, a.level); err != nil {
+		return 0, 0, fmt.Errorf("set compression for %q: %w", file.Path, err)
+	}
+
+	size, err := a.copy(io.MultiWriter(file.Compressor, hasher), file)
 	if err != nil {
-		return fmt.Errorf("open %q: %w", file.Path, err)
+		return 0, 0, fmt.Errorf("copy %q: %w", file.Path, err)
+	}
+
+	if err := file.Compressor.Close(); err != nil {
+		return 0, 0, fmt.Errorf("close compressor for %q: %w", file.Path, err)
+	}
+
+	return hasher.Sum32(), size, nil
+}
+
+// compressesInBlocks reports whether file is large enough to be split into blocks
+// that are compressed concurrently.
+func (a *archiver) compressesInBlocks(file *pool.File) bool {
+	// reproducible archives are compressed the same way regardless of concurrency
+	return (a.concurrency > 1 || a.reproducible) && file.Info.Mode().IsRegular() && file.Info.Size() >= a.parallelThreshold
+}
+
+// copy copies the contents of file to w, returning the number of bytes copied.
+func (a *archiver) copy(w io.Writer, file *pool.File) (int64, error) {
+	f, err := a.openFile(file)
+	if err != nil {
+		return 0, fmt.Errorf("open %q: %w", file.Path, err)
 	}
 	defer f.Close()
 
 	buf := bufferPool.Get().(*bufio.Reader)
-	buf.Reset(f)
+	buf.Reset(a.progress.reader(f))
 
-	_, err = io.Copy(w, buf)
+	n, err := io.Copy(w, buf)
 	bufferPool.Put(buf)
 	if err != nil {
-		return fmt.Errorf("copy %q: %w", file.Path, err)
+		return n, fmt.Errorf("copy %q: %w", file.Path, err)
 	}
 
-	return nil
+	return n, nil
 }
 
 func (a *archiver) populateHeader(file *pool.File) error {
 	header := file.Header
 
+	if a.reproducible {
+		a.normalize(header)
+	}
+
+	if a.entryComment != nil {
+		name := entryName(file)
+		if comment := a.entryComment(name); comment != "" {
+			if len(comment) > uint16max {
+				return fmt.Errorf("comment of %q of %d bytes is longer than %d bytes", name, len(comment), uint16max)
+			}
+			header.Comment = comment
+		}
+	}
+
 	utf8ValidName, utf8RequireName := detectUTF8(header.Name)
 	utf8ValidComment, utf8RequireComment := detectUTF8(header.Comment)
 	switch {
@@ -261,21 +827,42 @@ func (a *archiver) populateHeader(file *pool.File) error {
 
 	// we store local times in header.Modified- other zip readers expect this
 	// we set extended timestamp (UTC) info as an Extra for compatibility
-	// we only set mod time, not time of last access or time of original creation
+	// we only set NTFS times, and time of last access and time of original creation, if configured to
+	// we set NTFS times after it, so that readers preferring the last time found use the most precise one
 	// https://libzip.org/specifications/extrafld.txt
 
 	if !header.Modified.IsZero() {
-		header.Extra = append(header.Extra, NewExtendedTimestampExtraField(header.Modified).Encode()...)
+		var accessed, created time.Time
+		if a.storeFileTimes && !a.reproducible {
+			accessed, created = fileTimes(file.Info)
+		}
+
+		timestamp := NewExtendedTimestampExtraField(header.Modified).WithAccessTime(accessed).WithCreationTime(created)
+		header.Extra = append(header.Extra, timestamp.Encode()...)
+		if a.storeFileTimes {
+			header.Extra = append(header.Extra, NewNTFSExtraField(header.Modified, accessed, created).Encode()...)
+		}
+	}
+
+	// the owner is kept so that it can be restored, as done by Info-ZIP, unless normalized for reproducibility
+	if uid, gid, ok := fileOwner(file.Info); ok && !a.reproducible {
+		header.Extra = append(header.Extra, NewUnixExtraField(uid, gid).Encode()...)
 	}
 
 	if file.Info.IsDir() {
-		header.Name += "/"
+		if !strings.HasSuffix(header.Name, "/") {
+			header.Name += "/"
+		}
 		header.Method = zip.Store
 		header.Flags &^= 0x8 // won't write data descriptor (crc32, comp, uncomp)
 		header.UncompressedSize64 = 0
 	} else {
-		header.Method = zip.Deflate
-		header.Flags |= 0x8 // will write data descriptor (crc32, comp, uncomp)
+		if header.Method == zip.Store {
+			// some readers don't support data descriptors for stored files
+			header.Flags &^= 0x8
+		} else {
+			header.Flags |= 0x8 // will write data descriptor (crc32, comp, uncomp)
+		}
 		header.CompressedSize64 = uint64(file.Written())
 	}
 
@@ -285,10 +872,30 @@ func (a *archiver) populateHeader(file *pool.File) error {
 }
 
 func (a *archiver) archive(file *pool.File) error {
-	fileWriter, err := a.w.CreateRaw(file.Header)
+	if file.Source != nil {
+		if err := copyEntry(a.w, file.Source); err != nil {
+			return fmt.Errorf("copy existing entry for %q: %w", file.Path, err)
+		}
+
+		a.progress.update(func(progress *Progress) {
+			progress.FilesWritten++
+			progress.BytesWritten += int64(file.Source.CompressedSize64)
+		})
+		a.report.addFile(file, a.reportEntries)
+		pool.FilePool.Put(file)
+		return nil
+	}
+
+	rawWriter, err := a.createRaw(file.Header)
 	if err != nil {
 		return fmt.Errorf("create raw for %q: %w", file.Path, err)
 	}
+	// the central directory only holds the modification time of the extended timestamp. zip.Writer has no way
+	// to set the central extra fields apart from the local ones, but it keeps header and writes the central
+	// directory from it when closing, so the extra fields are replaced once the local header is written. This
+	// is pinned by TestZipWriterCentralExtra
+	file.Header.Extra = centralExtra(file.Header.Extra)
+	fileWriter := a.progress.writer(rawWriter)
 
 	if _, err = io.Copy(fileWriter, file.CompressedData); err != nil {
 		return fmt.Errorf("write compressed data for %q: %w", file.Path, err)
@@ -308,11 +915,36 @@ func (a *archiver) archive(file *pool.File) error {
 		}
 	}
 
+	a.progress.update(func(progress *Progress) {
+		progress.FilesWritten++
+	})
+	a.report.addFile(file, a.reportEntries)
 	pool.FilePool.Put(file)
 
 	return nil
 }
 
+// createRaw adds an entry described by header to the archive, returning a writer for its compressed contents.
+// The local file header of an entry of a split archive is kept within a single volume. As the sizes and
+// CRC-32 of the contents are known, it's written without a data descriptor, which would otherwise follow
+// the contents and be part of the central directory captured when closing.
+func (a *archiver) createRaw(header *zip.FileHeader) (io.Writer, error) {
+	if a.split != nil {
+		header.Flags &^= dataDescriptorFlag
+		if err := a.w.Flush(); err != nil {
+			return nil, fmt.Errorf("flush zip writer: %w", err)
+		}
+
+		// a Zip64 extra field is added to the local file header of large entries
+		size := fileHeaderLen + len(header.Name) + len(header.Extra) + zip64ExtraMaxLen
+		if err := a.split.reserve(int64(size)); err != nil {
+			return nil, fmt.Errorf("reserve local file header: %w", err)
+		}
+	}
+
+	return a.w.CreateRaw(header)
+}
+
 // https://cs.opensource.google/go/go/+/refs/tags/go1.21.0:src/archive/zip/writer.go
 func detectUTF8(s string) (valid, require bool) {
 	for i := 0; i < len(s); {
@@ -1,11 +1,25 @@
 package pzip
 
 import (
+	"archive/zip"
+	"errors"
 	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/zstd"
 )
 
 const minConcurrency = 1
 
+// Zstd is the zip method for Zstandard compressed files, as written by WinZip.
+const Zstd uint16 = zstd.ZipMethodWinZip
+
 type archiverOption func(*archiver) error
 
 // ArchiverConcurrency sets the number of goroutines used during archiving
@@ -20,3 +34,342 @@ func ArchiverConcurrency(n int) archiverOption {
 		return nil
 	}
 }
+
+// ArchiverMethod sets the zip method used to compress files. Supported methods are
+// zip.Store, zip.Deflate and Zstd. An error is returned for any other method.
+func ArchiverMethod(method uint16) archiverOption {
+	return func(a *archiver) error {
+		switch method {
+		case zip.Store, zip.Deflate, Zstd:
+		default:
+			return fmt.Errorf("unsupported compression method %d", method)
+		}
+
+		a.method = method
+		return nil
+	}
+}
+
+// ArchiverCompressionLevel sets the level used to compress files, ranging from 0 (no compression)
+// and 1 (best speed) to 9 (best compression). A level of -1 uses the default level of the compression method.
+// An error is returned if n is outside of this range.
+func ArchiverCompressionLevel(n int) archiverOption {
+	return func(a *archiver) error {
+		if n < flate.DefaultCompression || n > flate.BestCompression {
+			return fmt.Errorf("compression level %d not between %d and %d", n, flate.DefaultCompression, flate.BestCompression)
+		}
+
+		a.level = n
+		return nil
+	}
+}
+
+// ArchiverStoreCompressed stores files whose contents are already compressed, rather than compressing them again.
+// Files are stored when their extension is one of extensions, or DefaultStoredExtensions if none are given.
+func ArchiverStoreCompressed(extensions ...string) archiverOption {
+	return func(a *archiver) error {
+		if len(extensions) == 0 {
+			extensions = DefaultStoredExtensions
+		}
+
+		a.storedExtensions = make(map[string]bool, len(extensions))
+		for _, extension := range extensions {
+			if !strings.HasPrefix(extension, ".") {
+				extension = "." + extension
+			}
+			a.storedExtensions[strings.ToLower(extension)] = true
+		}
+
+		return nil
+	}
+}
+
+// ArchiverSampleCompressed stores files whose contents barely compress, deciding from how well the first 64 KiB
+// of each file compresses. Entries added from readers aren't sampled.
+func ArchiverSampleCompressed() archiverOption {
+	return func(a *archiver) error {
+		a.sampleCompressed = true
+		return nil
+	}
+}
+
+// ArchiverStoreSymlinks stores symbolic links as links, rather than archiving the files and directories
+// they refer to. Without it, an error is returned during archiving for links that form a cycle.
+func ArchiverStoreSymlinks() archiverOption {
+	return func(a *archiver) error {
+		a.storeSymlinks = true
+		return nil
+	}
+}
+
+// ArchiverInclude only archives files whose archive names match one of patterns. Patterns use doublestar
+// glob syntax, so that "**/*.go" matches Go files in any directory. Directories are walked regardless, but
+// only archived if they match. An error is returned if a pattern is malformed.
+func ArchiverInclude(patterns ...string) archiverOption {
+	return func(a *archiver) error {
+		if err := validatePatterns(patterns); err != nil {
+			return err
+		}
+
+		a.filter.includes = append(a.filter.includes, patterns...)
+		return nil
+	}
+}
+
+// ArchiverExclude skips files whose archive names match one of patterns. Patterns use doublestar glob syntax,
+// so that "**/node_modules" matches node_modules directories at any depth. Excluded directories aren't walked.
+// An error is returned if a pattern is malformed.
+func ArchiverExclude(patterns ...string) archiverOption {
+	return func(a *archiver) error {
+		if err := validatePatterns(patterns); err != nil {
+			return err
+		}
+
+		a.filter.excludes = append(a.filter.excludes, patterns...)
+		return nil
+	}
+}
+
+func validatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if !doublestar.ValidatePattern(pattern) {
+			return fmt.Errorf("invalid pattern %q", pattern)
+		}
+	}
+
+	return nil
+}
+
+// ArchiverIgnoreFiles skips files that are ignored by ignore files with the given names, or DefaultIgnoreFiles
+// if none are given. Ignore files use .gitignore syntax, including negated and directory patterns, and apply to the
+// directory containing them and all directories beneath it. When .gitignore files are honored, .git directories are
+// skipped too, so that archives match what git would track.
+func ArchiverIgnoreFiles(names ...string) archiverOption {
+	return func(a *archiver) error {
+		if len(names) == 0 {
+			names = DefaultIgnoreFiles
+		}
+
+		a.ignorer = newIgnorer(names, a.open)
+		return nil
+	}
+}
+
+// ArchiverEncryption encrypts the contents of files with password, using WinZip AES encryption with keys of
+// keySize bits, which is one of 128, 192 or 256. Files are encrypted once they are compressed. The names of files
+// and the contents of directories and symbolic links aren't encrypted. An error is returned if password is empty
+// or the key size is unsupported.
+func ArchiverEncryption(password string, keySize int) archiverOption {
+	return func(a *archiver) error {
+		if password == "" {
+			return errors.New("empty password")
+		}
+
+		strength, err := aesStrength(keySize)
+		if err != nil {
+			return err
+		}
+
+		a.encrypter = &aesEncrypter{password: []byte(password), strength: strength}
+		return nil
+	}
+}
+
+// ArchiverZipCrypto encrypts the contents of files with password, using the traditional PKWARE encryption known
+// as ZipCrypto. ZipCrypto is weak and can be broken, so it should only be used for tools which don't support AES
+// encryption, as used by ArchiverEncryption. An error is returned if password is empty.
+func ArchiverZipCrypto(password string) archiverOption {
+	return func(a *archiver) error {
+		if password == "" {
+			return errors.New("empty password")
+		}
+
+		a.encrypter = &zipCryptoEncrypter{password: []byte(password)}
+		return nil
+	}
+}
+
+// ArchiverUpdate updates the existing archive at path, as done by zip -u. Entries of files that are unchanged,
+// having the same size and modification time, are copied from the existing archive without being compressed
+// again, while new or modified files are compressed. Entries of files that aren't archived are kept. As the
+// existing archive is read while archiving, the archiver must write to a different file, which can replace
+// the existing archive once the archiver is closed. When encrypting, entries which aren't encrypted in the same
+// way are compressed and encrypted again, or removed if their files aren't archived, so that the archive holds
+// no unencrypted contents. An error is returned if the archive can't be opened.
+func ArchiverUpdate(path string) archiverOption {
+	return archiverUpdate(path, false)
+}
+
+// ArchiverSync updates the existing archive at path in the same way as ArchiverUpdate, except that the
+// entries of files that aren't archived are removed, so that the archive mirrors the archived files, as
+// done by zip -FS. An error is returned if the archive can't be opened.
+func ArchiverSync(path string) archiverOption {
+	return archiverUpdate(path, true)
+}
+
+func archiverUpdate(path string, sync bool) archiverOption {
+	return func(a *archiver) error {
+		if a.split != nil {
+			return errors.New("split archives can't be updated")
+		}
+
+		updater, err := newUpdater(path, sync)
+		if err != nil {
+			return fmt.Errorf("updater: %w", err)
+		}
+
+		if updater.path == a.absoluteArchivePath {
+			updater.close()
+			return fmt.Errorf("archive %q can't be written while it's updated", path)
+		}
+
+		a.updater = updater
+		return nil
+	}
+}
+
+// ArchiverOrdered writes entries in the order in which files are given to Archive and found while walking
+// directories, rather than the order in which their compression finishes, for consumers which depend on
+// the order of entries. Files are still compressed concurrently, but compression only gets a few files
+// ahead of the next file to write, so that memory use stays bounded.
+func ArchiverOrdered() archiverOption {
+	return func(a *archiver) error {
+		a.ordered = true
+		return nil
+	}
+}
+
+// ArchiverFileTimes stores the times of files as NTFS timestamps, with a precision of 100ns, and stores their access
+// and creation times, where the operating system reports them, alongside their modification times. Access times
+// change whenever files are read, and creation times whenever files are copied, so neither is stored in
+// reproducible archives.
+func ArchiverFileTimes() archiverOption {
+	return func(a *archiver) error {
+		a.storeFileTimes = true
+		return nil
+	}
+}
+
+// ArchiverComment sets the comment of the archive, as done by zip -z. Updated archives keep their existing
+// comment unless it's set. An error is returned if comment is longer than 65535 bytes.
+func ArchiverComment(comment string) archiverOption {
+	return func(a *archiver) error {
+		if len(comment) > uint16max {
+			return fmt.Errorf("archive comment of %d bytes is longer than %d bytes", len(comment), uint16max)
+		}
+
+		a.comment = comment
+		a.commented = true
+		return nil
+	}
+}
+
+// ArchiverEntryComments sets the comments of entries to those returned by comment, which is called with the
+// name of each entry, ending with a slash for directories. Entries are left without a comment, or with the
+// comment of their header for readers added with AddReader, when comment returns an empty string. Entries
+// copied from an updated archive keep their existing comment. An error naming the entry is returned when
+// archiving it if its comment is longer than 65535 bytes.
+func ArchiverEntryComments(comment func(name string) string) archiverOption {
+	return func(a *archiver) error {
+		a.entryComment = comment
+		return nil
+	}
+}
+
+// ArchiverProgress reports the progress of archiving to report, as files are found, read, compressed and written.
+// Progress is reported at most every 100ms, and once more when Archive returns. Calls to report are serialized,
+// but made from the goroutines archiving files, so report should return quickly. Entries of an updated archive
+// that are kept, which are copied when the archiver is closed, aren't reported.
+func ArchiverProgress(report func(progress Progress)) archiverOption {
+	return func(a *archiver) error {
+		a.progress = newProgressTracker(report)
+		return nil
+	}
+}
+
+// ArchiverReport lists every entry in the report returned by Report, rather than only totals for the archive,
+// describing the size, compressed size and method of each entry and the time spent compressing it.
+func ArchiverReport() archiverOption {
+	return func(a *archiver) error {
+		a.reportEntries = true
+		return nil
+	}
+}
+
+// ArchiverDryRun lists the entries that would be archived, rather than archiving them. Files are found, filtered
+// and named as when archiving, and list is called with the name and file info of each entry, but their contents
+// aren't read and nothing is written to the archive, not even when the archiver is closed. Entries kept from an
+// updated archive aren't listed.
+func ArchiverDryRun(list func(name string, info fs.FileInfo)) archiverOption {
+	return func(a *archiver) error {
+		a.dryRun = list
+		return nil
+	}
+}
+
+// ArchiverPrefix places the entries of archived files under the directory prefix, such as "myapp-1.2", so that
+// files are extracted into it. Entries added with AddReader keep the names of their headers. Include and exclude
+// patterns are matched against names without the prefix. An error is returned if prefix is absolute or refers
+// outside of the archive.
+func ArchiverPrefix(prefix string) archiverOption {
+	return func(a *archiver) error {
+		if err := validateName(prefix); err != nil {
+			return err
+		}
+
+		a.prefix = path.Clean(prefix)
+		if a.prefix == "." {
+			a.prefix = ""
+		}
+		return nil
+	}
+}
+
+// ArchiverRootNames names the entries of the roots given to Archive after the destinations they're mapped to in
+// roots, rather than after the roots themselves, so that the files of "./build/out" can be archived under "bin"
+// rather than "out". Roots are matched once cleaned. An empty destination, or ".", places the contents of a root
+// directory at the top of the archive. Destinations come before the prefix set by ArchiverPrefix. Include and
+// exclude patterns are matched against names before they're mapped. An error is returned if a destination is
+// absolute or refers outside of the archive.
+func ArchiverRootNames(roots map[string]string) archiverOption {
+	return func(a *archiver) error {
+		a.rootNames = make(map[string]string, len(roots))
+		for root, name := range roots {
+			if err := validateName(name); err != nil {
+				return fmt.Errorf("root %q: %w", root, err)
+			}
+			a.rootNames[filepath.Clean(root)] = path.Clean(name)
+		}
+
+		return nil
+	}
+}
+
+// ArchiverRename renames entries using rename, which is called with the name of each file found, once roots are
+// mapped and the prefix is added, and returns the name of its entry or whether the file is skipped. Names of
+// directories end with a slash, which may be left out of the returned name. Skipping a directory only skips its
+// entry, not the files within it.
+// Include and exclude patterns are matched against names before they're renamed, and entries added with
+// AddReader aren't renamed.
+func ArchiverRename(rename func(path string) (name string, skip bool)) archiverOption {
+	return func(a *archiver) error {
+		a.renameFunc = rename
+		return nil
+	}
+}
+
+// ArchiverReproducible makes archives of the same files byte-for-byte identical, regardless of when, where and
+// by whom they're created. Entries are written sorted by name, modification times later than sourceDate are
+// clamped to it, as for SOURCE_DATE_EPOCH, and permissions are normalized to 0644, or 0755 for directories and
+// executables. A zero sourceDate uses DefaultSourceDate. Reproducible archives can't be encrypted or updated.
+func ArchiverReproducible(sourceDate time.Time) archiverOption {
+	return func(a *archiver) error {
+		if sourceDate.IsZero() {
+			sourceDate = DefaultSourceDate
+		}
+
+		a.reproducible = true
+		a.sourceDate = sourceDate
+		return nil
+	}
+}
@@ -6,11 +6,17 @@ import (
 	"context"
 	"encoding/binary"
 	"fmt"
+	"io"
+	"io/fs"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/alecthomas/assert/v2"
+	"github.com/klauspost/compress/flate"
 	"github.com/ybirader/pzip/internal/testutils"
 	"github.com/ybirader/pzip/pool"
 )
@@ -122,6 +128,190 @@ func TestArchive(t *testing.T) {
 	})
 }
 
+func TestArchiveToWriter(t *testing.T) {
+	t.Run("writes the archive to any writer", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		archiver, err := NewArchiver(&buf)
+		assert.NoError(t, err)
+		err = archiver.Archive(context.Background(), []string{helloDirectoryFixture})
+		assert.NoError(t, err)
+		archiver.Close()
+
+		archiveReader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		assert.NoError(t, err)
+
+		assert.Equal(t, 4, len(archiveReader.File))
+		testutils.AssertArchiveContainsFile(t, archiveReader.File, "hello/nested/hello.md")
+	})
+}
+
+func TestArchiveFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"README.md":         {Data: []byte("# hello"), Mode: 0644},
+		"assets/app.js":     {Data: []byte("console.log('hello')"), Mode: 0644},
+		"assets/style.css":  {Data: []byte("body {}"), Mode: 0644},
+		"assets/.gitignore": {Data: []byte("*.css"), Mode: 0644},
+	}
+
+	t.Run("archives the whole file system", func(t *testing.T) {
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		archiver, err := NewArchiver(archive)
+		assert.NoError(t, err)
+		err = archiver.ArchiveFS(context.Background(), fsys, ".")
+		assert.NoError(t, err)
+		archiver.Close()
+
+		archiveReader := testutils.GetArchiveReader(t, archive.Name())
+		defer archiveReader.Close()
+
+		assert.Equal(t, 5, len(archiveReader.File))
+		testutils.AssertArchiveContainsFile(t, archiveReader.File, "assets/")
+		readme, found := testutils.Find(archiveReader.File, func(file *zip.File) bool {
+			return file.Name == "README.md"
+		})
+		assert.True(t, found)
+		assert.Equal(t, "# hello", readArchivedFile(t, readme))
+	})
+
+	t.Run("archives directories and files of the file system", func(t *testing.T) {
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		archiver, err := NewArchiver(archive, ArchiverIgnoreFiles())
+		assert.NoError(t, err)
+		err = archiver.ArchiveFS(context.Background(), fsys, "assets", "README.md")
+		assert.NoError(t, err)
+		archiver.Close()
+
+		archiveReader := testutils.GetArchiveReader(t, archive.Name())
+		defer archiveReader.Close()
+
+		assert.Equal(t, 4, len(archiveReader.File))
+		testutils.AssertArchiveContainsFile(t, archiveReader.File, "assets/app.js")
+		testutils.AssertArchiveContainsFile(t, archiveReader.File, "README.md")
+	})
+}
+
+func TestAddReader(t *testing.T) {
+	t.Run("archives entries from readers alongside files", func(t *testing.T) {
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		archiver, err := NewArchiver(archive)
+		assert.NoError(t, err)
+
+		modified := time.Date(2023, 8, 1, 12, 0, 0, 0, time.UTC)
+		err = archiver.AddReader(&zip.FileHeader{Name: "meta/", Modified: modified}, nil)
+		assert.NoError(t, err)
+		err = archiver.AddReader(&zip.FileHeader{Name: "meta/manifest.json", Modified: modified}, strings.NewReader(`{"version": 1}`))
+		assert.NoError(t, err)
+
+		err = archiver.Archive(context.Background(), []string{helloTxtFileFixture})
+		assert.NoError(t, err)
+		archiver.Close()
+
+		archiveReader := testutils.GetArchiveReader(t, archive.Name())
+		defer archiveReader.Close()
+
+		assert.Equal(t, 3, len(archiveReader.File))
+		testutils.AssertArchiveContainsFile(t, archiveReader.File, "meta/")
+		testutils.AssertArchiveContainsFile(t, archiveReader.File, "hello.txt")
+
+		manifest, found := testutils.Find(archiveReader.File, func(file *zip.File) bool {
+			return file.Name == "meta/manifest.json"
+		})
+		assert.True(t, found)
+		assert.Equal(t, `{"version": 1}`, readArchivedFile(t, manifest))
+		assert.Equal(t, uint64(len(`{"version": 1}`)), manifest.UncompressedSize64)
+		assertMatchingTimes(t, modified, manifest.Modified)
+	})
+
+	t.Run("returns an error for headers without a name", func(t *testing.T) {
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		archiver, err := NewArchiver(archive)
+		assert.NoError(t, err)
+
+		err = archiver.AddReader(&zip.FileHeader{}, strings.NewReader("hello"))
+		assert.Error(t, err)
+	})
+}
+
+func TestArchiveSymlinks(t *testing.T) {
+	t.Run("stores symbolic links as links", func(t *testing.T) {
+		dirPath := createSymlinkFixture(t)
+
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		archiver, err := NewArchiver(archive, ArchiverStoreSymlinks())
+		assert.NoError(t, err)
+		err = archiver.Archive(context.Background(), []string{dirPath})
+		assert.NoError(t, err)
+		archiver.Close()
+
+		archiveReader := testutils.GetArchiveReader(t, archive.Name())
+		defer archiveReader.Close()
+
+		link, found := testutils.Find(archiveReader.File, func(file *zip.File) bool {
+			return file.Name == "links/link.txt"
+		})
+		assert.True(t, found)
+		assert.True(t, link.Mode()&fs.ModeSymlink != 0)
+		assert.Equal(t, zip.Store, link.Method)
+		assert.Equal(t, "hello.txt", readArchivedFile(t, link))
+
+		_, found = testutils.Find(archiveReader.File, func(file *zip.File) bool {
+			return file.Name == "links/linked/hello.txt"
+		})
+		assert.False(t, found)
+	})
+
+	t.Run("archives the targets of symbolic links by default", func(t *testing.T) {
+		dirPath := createSymlinkFixture(t)
+
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		archiver, err := NewArchiver(archive)
+		assert.NoError(t, err)
+		err = archiver.Archive(context.Background(), []string{dirPath})
+		assert.NoError(t, err)
+		archiver.Close()
+
+		archiveReader := testutils.GetArchiveReader(t, archive.Name())
+		defer archiveReader.Close()
+
+		link, found := testutils.Find(archiveReader.File, func(file *zip.File) bool {
+			return file.Name == "links/link.txt"
+		})
+		assert.True(t, found)
+		assert.True(t, link.Mode().IsRegular())
+		assert.Equal(t, "hello, world!", readArchivedFile(t, link))
+
+		testutils.AssertArchiveContainsFile(t, archiveReader.File, "links/linked/")
+		testutils.AssertArchiveContainsFile(t, archiveReader.File, "links/linked/hello.txt")
+	})
+
+	t.Run("returns an error for symbolic links that form a cycle", func(t *testing.T) {
+		dirPath := createSymlinkFixture(t)
+		err := os.Symlink(".", filepath.Join(dirPath, "loop"))
+		assert.NoError(t, err)
+
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		archiver, err := NewArchiver(archive)
+		assert.NoError(t, err)
+		err = archiver.Archive(context.Background(), []string{dirPath})
+		assert.Error(t, err)
+	})
+}
+
 func TestCompress(t *testing.T) {
 	t.Run("when file has compressed size less than or equal to buffer size", func(t *testing.T) {
 		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
@@ -170,6 +360,62 @@ func TestCompress(t *testing.T) {
 		assert.Equal(t, file.Written(), int64(file.Header.CompressedSize64))
 	})
 
+	t.Run("with store method", func(t *testing.T) {
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		archiver, err := NewArchiver(archive, ArchiverMethod(zip.Store))
+		assert.NoError(t, err)
+
+		info := testutils.GetFileInfo(t, helloTxtFileFixture)
+		file, err := pool.NewFile(helloTxtFileFixture, info, "")
+		assert.NoError(t, err)
+
+		err = archiver.compress(file)
+		assert.NoError(t, err)
+
+		assert.Equal(t, zip.Store, file.Header.Method)
+		assert.Equal(t, file.Header.UncompressedSize64, file.Header.CompressedSize64)
+		assert.Zero(t, file.Header.Flags&0x8)
+	})
+
+	t.Run("with zstd method", func(t *testing.T) {
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		archiver, err := NewArchiver(archive, ArchiverMethod(Zstd))
+		assert.NoError(t, err)
+
+		info := testutils.GetFileInfo(t, helloTxtFileFixture)
+		file, err := pool.NewFile(helloTxtFileFixture, info, "")
+		assert.NoError(t, err)
+
+		err = archiver.compress(file)
+		assert.NoError(t, err)
+
+		assert.Equal(t, Zstd, file.Header.Method)
+		assert.NotZero(t, file.Header.CRC32)
+		assert.Equal(t, uint64(file.Written()), file.Header.CompressedSize64)
+	})
+
+	t.Run("with compression level", func(t *testing.T) {
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		archiver, err := NewArchiver(archive, ArchiverCompressionLevel(flate.NoCompression))
+		assert.NoError(t, err)
+
+		info := testutils.GetFileInfo(t, helloTxtFileFixture)
+		file, err := pool.NewFile(helloTxtFileFixture, info, "")
+		assert.NoError(t, err)
+
+		err = archiver.compress(file)
+		assert.NoError(t, err)
+
+		assert.Equal(t, zip.Deflate, file.Header.Method)
+		assertGreaterThan(t, int64(file.Header.CompressedSize64), int64(file.Header.UncompressedSize64))
+	})
+
 	t.Run("for directories", func(t *testing.T) {
 		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
 		defer cleanup()
@@ -195,6 +441,57 @@ func TestCompress(t *testing.T) {
 	})
 }
 
+func TestArchiverMethod(t *testing.T) {
+	t.Run("returns an error for unsupported methods", func(t *testing.T) {
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		_, err := NewArchiver(archive, ArchiverMethod(99))
+		assert.Error(t, err)
+	})
+}
+
+func TestArchiverCompressionLevel(t *testing.T) {
+	t.Run("returns an error for levels out of range", func(t *testing.T) {
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		_, err := NewArchiver(archive, ArchiverCompressionLevel(10))
+		assert.Error(t, err)
+
+		_, err = NewArchiver(archive, ArchiverCompressionLevel(-2))
+		assert.Error(t, err)
+	})
+}
+
+// createSymlinkFixture creates a directory named links containing a file, a directory, and
+// symbolic links to each of them.
+func createSymlinkFixture(t testing.TB) string {
+	t.Helper()
+
+	dirPath := filepath.Join(t.TempDir(), "links")
+	assert.NoError(t, os.MkdirAll(filepath.Join(dirPath, "dir"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dirPath, "hello.txt"), []byte("hello, world!"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dirPath, "dir", "hello.txt"), []byte("hello, world!"), 0644))
+	assert.NoError(t, os.Symlink("hello.txt", filepath.Join(dirPath, "link.txt")))
+	assert.NoError(t, os.Symlink("dir", filepath.Join(dirPath, "linked")))
+
+	return dirPath
+}
+
+func readArchivedFile(t testing.TB, file *zip.File) string {
+	t.Helper()
+
+	rc, err := file.Open()
+	assert.NoError(t, err)
+	defer rc.Close()
+
+	contents, err := io.ReadAll(rc)
+	assert.NoError(t, err)
+
+	return string(contents)
+}
+
 func assertExtendedTimestamp(t testing.TB, extraField []byte) {
 	want := make([]byte, 2)
 	binary.LittleEndian.PutUint16(want, extendedTimestampTag)
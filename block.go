@@ -0,0 +1,239 @@
+package pzip
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/flate"
+	"github.com/ybirader/pzip/pool"
+)
+
+const (
+	defaultBlockSize         = 1024 * 1024
+	defaultParallelThreshold = 16 * defaultBlockSize
+	dictionarySize           = 32 * 1024
+)
+
+// finalBlock is an empty, final DEFLATE block using fixed Huffman codes. It terminates
+// a stream made up of sync flushed blocks.
+var finalBlock = []byte{0x03, 0x00}
+
+var blockPool = sync.Pool{
+	New: func() any {
+		return &block{compressed: new(bytes.Buffer)}
+	},
+}
+
+// A block is an independently compressed chunk of a file. Blocks are primed with the
+// tail of the preceding chunk, so their compressed output can be concatenated to form a
+// single DEFLATE stream.
+type block struct {
+	data       []byte
+	dict       []byte
+	compressed *bytes.Buffer
+	crc        uint32
+	done       chan struct{}
+	err        error
+}
+
+func (b *block) reset(size int) {
+	if cap(b.data) < size {
+		b.data = make([]byte, size)
+	}
+	b.data = b.data[:size]
+	b.dict = b.dict[:0]
+	b.compressed.Reset()
+	b.crc = 0
+	b.done = make(chan struct{})
+	b.err = nil
+}
+
+func (b *block) compress(compressor *flate.Writer) error {
+	compressor.ResetDict(b.compressed, b.dict)
+
+	if _, err := compressor.Write(b.data); err != nil {
+		return fmt.Errorf("compress block: %w", err)
+	}
+
+	if err := compressor.Flush(); err != nil {
+		return fmt.Errorf("flush block: %w", err)
+	}
+
+	b.crc = crc32.ChecksumIEEE(b.data)
+	return nil
+}
+
+// compressBlocks splits the file into blocks and compresses them concurrently. The compressed
+// blocks are written to file in order, followed by a final empty block. It returns the CRC-32 of
+// the uncompressed contents, combined from the checksums of each block, and their size.
+// Blocks take a slot of blockSlots until they're written, so the blocks of all files being
+// compressed share concurrency compressors.
+func (a *archiver) compressBlocks(file *pool.File) (uint32, int64, error) {
+	f, err := a.openFile(file)
+	if err != nil {
+		return 0, 0, fmt.Errorf("open %q: %w", file.Path, err)
+	}
+	defer f.Close()
+
+	blocks := make(chan *block, a.concurrency)
+	stop := make(chan struct{})
+	readErr := make(chan error, 1)
+
+	go func() {
+		readErr <- a.readBlocks(a.progress.reader(f), blocks, stop)
+		close(blocks)
+	}()
+
+	var crc uint32
+	var size int64
+	for b := range blocks {
+		<-b.done
+
+		if err == nil {
+			if err = b.err; err == nil {
+				if _, err = file.Write(b.compressed.Bytes()); err != nil {
+					err = fmt.Errorf("write block: %w", err)
+				}
+			}
+			if err != nil {
+				close(stop)
+			}
+		}
+
+		crc = crc32Combine(crc, b.crc, int64(len(b.data)))
+		size += int64(len(b.data))
+		blockPool.Put(b)
+		<-a.blockSlots
+	}
+
+	if rerr := <-readErr; rerr != nil && err == nil {
+		err = rerr
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if _, err = file.Write(finalBlock); err != nil {
+		return 0, 0, fmt.Errorf("write final block of %q: %w", file.Path, err)
+	}
+
+	return crc, size, nil
+}
+
+// readBlocks reads r in blocks, starting the compression of each block as it is read, and
+// sends the blocks in order on blocks. Reading stops early when stop is closed or the context
+// of the archive is canceled.
+func (a *archiver) readBlocks(r io.Reader, blocks chan<- *block, stop <-chan struct{}) error {
+	dict := make([]byte, 0, dictionarySize)
+
+	for {
+		select {
+		case a.blockSlots <- struct{}{}:
+		case <-stop:
+			return nil
+		case <-a.ctx.Done():
+			return a.ctx.Err()
+		}
+
+		b := blockPool.Get().(*block)
+		b.reset(a.blockSize)
+
+		n, err := io.ReadFull(r, b.data)
+		if err == io.EOF {
+			blockPool.Put(b)
+			<-a.blockSlots
+			return nil
+		} else if err != nil && err != io.ErrUnexpectedEOF {
+			blockPool.Put(b)
+			<-a.blockSlots
+			return fmt.Errorf("read block: %w", err)
+		}
+		b.data = b.data[:n]
+		b.dict = append(b.dict, dict...)
+		dict = append(dict[:0], b.data[max(0, n-dictionarySize):]...)
+
+		go func() {
+			compressor := a.blockCompressors.Get().(*flate.Writer)
+			b.err = b.compress(compressor)
+			a.blockCompressors.Put(compressor)
+			close(b.done)
+		}()
+
+		select {
+		case blocks <- b:
+		case <-stop:
+			<-b.done
+			blockPool.Put(b)
+			<-a.blockSlots
+			return nil
+		}
+
+		if err == io.ErrUnexpectedEOF {
+			return nil
+		}
+	}
+}
+
+// crc32Combine returns the CRC-32 of the concatenation of two byte sequences, given the checksum
+// of each sequence and the length of the second. It is a port of crc32_combine from zlib.
+func crc32Combine(crc1, crc2 uint32, len2 int64) uint32 {
+	if len2 <= 0 {
+		return crc1
+	}
+
+	var even, odd [32]uint32
+
+	// odd holds the operator for one zero bit
+	odd[0] = crc32.IEEE
+	row := uint32(1)
+	for n := 1; n < 32; n++ {
+		odd[n] = row
+		row <<= 1
+	}
+
+	gf2MatrixSquare(&even, &odd) // two zero bits
+	gf2MatrixSquare(&odd, &even) // four zero bits
+
+	// apply len2 zeros to crc1, the first square puts the operator for one zero byte in even
+	for {
+		gf2MatrixSquare(&even, &odd)
+		if len2&1 != 0 {
+			crc1 = gf2MatrixTimes(&even, crc1)
+		}
+		len2 >>= 1
+		if len2 == 0 {
+			break
+		}
+
+		gf2MatrixSquare(&odd, &even)
+		if len2&1 != 0 {
+			crc1 = gf2MatrixTimes(&odd, crc1)
+		}
+		len2 >>= 1
+		if len2 == 0 {
+			break
+		}
+	}
+
+	return crc1 ^ crc2
+}
+
+func gf2MatrixTimes(mat *[32]uint32, vec uint32) uint32 {
+	var sum uint32
+	for i := 0; vec != 0; i++ {
+		if vec&1 != 0 {
+			sum ^= mat[i]
+		}
+		vec >>= 1
+	}
+	return sum
+}
+
+func gf2MatrixSquare(square, mat *[32]uint32) {
+	for n := 0; n < 32; n++ {
+		square[n] = gf2MatrixTimes(mat, mat[n])
+	}
+}
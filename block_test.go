@@ -0,0 +1,156 @@
+package pzip
+
+import (
+	"context"
+	"hash/crc32"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/ybirader/pzip/internal/testutils"
+	"github.com/ybirader/pzip/pool"
+)
+
+func TestCompressBlocks(t *testing.T) {
+	t.Run("compresses a large file in blocks to a single valid entry", func(t *testing.T) {
+		filePath, contents := createLargeFile(t, 300*1024)
+
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		archiver, err := NewArchiver(archive, ArchiverConcurrency(4))
+		assert.NoError(t, err)
+		archiver.blockSize = 64 * 1024
+		archiver.parallelThreshold = 128 * 1024
+
+		info := testutils.GetFileInfo(t, filePath)
+		file, err := pool.NewFile(filePath, info, "")
+		assert.NoError(t, err)
+		assert.True(t, archiver.compressesInBlocks(file))
+		pool.FilePool.Put(file)
+
+		err = archiver.Archive(context.Background(), []string{filePath})
+		assert.NoError(t, err)
+		archiver.Close()
+
+		archiveReader := testutils.GetArchiveReader(t, archive.Name())
+		defer archiveReader.Close()
+
+		assert.Equal(t, 1, len(archiveReader.File))
+		assert.Equal(t, crc32.ChecksumIEEE(contents), archiveReader.File[0].CRC32)
+
+		rc, err := archiveReader.File[0].Open()
+		assert.NoError(t, err)
+		defer rc.Close()
+
+		got, err := io.ReadAll(rc)
+		assert.NoError(t, err)
+		assert.Equal(t, contents, got)
+	})
+
+	t.Run("compresses small files as a whole", func(t *testing.T) {
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		archiver, err := NewArchiver(archive)
+		assert.NoError(t, err)
+
+		info := testutils.GetFileInfo(t, helloTxtFileFixture)
+		file, err := pool.NewFile(helloTxtFileFixture, info, "")
+		assert.NoError(t, err)
+
+		assert.False(t, archiver.compressesInBlocks(file))
+	})
+
+	t.Run("shares block compressors across large files", func(t *testing.T) {
+		// each large file has the same contents
+		var filePaths []string
+		var contents []byte
+		for i := 0; i < 3; i++ {
+			var filePath string
+			filePath, contents = createLargeFile(t, 300*1024)
+			filePaths = append(filePaths, filePath)
+		}
+
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		archiver, err := NewArchiver(archive, ArchiverConcurrency(2))
+		assert.NoError(t, err)
+		archiver.blockSize = 16 * 1024
+		archiver.parallelThreshold = 128 * 1024
+
+		err = archiver.Archive(context.Background(), filePaths)
+		assert.NoError(t, err)
+		archiver.Close()
+		assert.Equal(t, 2, cap(archiver.blockSlots))
+		assert.Equal(t, 0, len(archiver.blockSlots))
+
+		archiveReader := testutils.GetArchiveReader(t, archive.Name())
+		defer archiveReader.Close()
+
+		assert.Equal(t, 3, len(archiveReader.File))
+		for _, file := range archiveReader.File {
+			assert.Equal(t, crc32.ChecksumIEEE(contents), file.CRC32)
+		}
+	})
+
+	t.Run("stops compressing blocks when the archive is canceled", func(t *testing.T) {
+		filePath, _ := createLargeFile(t, 300*1024)
+
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		archiver, err := NewArchiver(archive, ArchiverConcurrency(4))
+		assert.NoError(t, err)
+		archiver.blockSize = 64 * 1024
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		archiver.ctx = ctx
+
+		file, err := pool.NewFile(filePath, testutils.GetFileInfo(t, filePath), "")
+		assert.NoError(t, err)
+		defer pool.FilePool.Put(file)
+
+		_, _, err = archiver.compressBlocks(file)
+		assert.IsError(t, err, context.Canceled)
+		assert.Equal(t, 0, len(archiver.blockSlots))
+	})
+}
+
+func TestCRC32Combine(t *testing.T) {
+	data := []byte("the quick brown fox jumps over the lazy dog, again and again and again")
+
+	for _, split := range []int{0, 1, 10, len(data) - 1, len(data)} {
+		crc1 := crc32.ChecksumIEEE(data[:split])
+		crc2 := crc32.ChecksumIEEE(data[split:])
+
+		got := crc32Combine(crc1, crc2, int64(len(data)-split))
+
+		assert.Equal(t, crc32.ChecksumIEEE(data), got)
+	}
+}
+
+// createLargeFile writes size bytes of compressible, non-repeating data to a temporary file.
+func createLargeFile(t testing.TB, size int) (string, []byte) {
+	t.Helper()
+
+	words := []string{"alpha ", "bravo ", "charlie ", "delta ", "echo ", "foxtrot ", "golf ", "hotel "}
+	r := rand.New(rand.NewSource(1))
+
+	contents := make([]byte, 0, size)
+	for len(contents) < size {
+		contents = append(contents, words[r.Intn(len(words))]...)
+	}
+	contents = contents[:size]
+
+	filePath := filepath.Join(t.TempDir(), "large.txt")
+	err := os.WriteFile(filePath, contents, 0644)
+	assert.NoError(t, err)
+
+	return filePath, contents
+}
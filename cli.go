@@ -1,46 +1,416 @@
 package pzip
 
 import (
+	"archive/zip"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"time"
 )
 
+// methods maps the names accepted by ArchiverCLI to their zip method
+var methods = map[string]uint16{
+	"store":   zip.Store,
+	"deflate": zip.Deflate,
+	"zstd":    Zstd,
+}
+
+const stdoutPath = "-"
+
+// PasswordEnv is the environment variable holding the password of encrypted archives, if not given as a flag.
+const PasswordEnv = "PZIP_PASSWORD"
+
+// SourceDateEpochEnv is the environment variable holding the time, in seconds since the Unix epoch, to which
+// modification times of reproducible archives are clamped (See https://reproducible-builds.org/specs/source-date-epoch/).
+const SourceDateEpochEnv = "SOURCE_DATE_EPOCH"
+
 type ArchiverCLI struct {
-	ArchivePath string
-	Files       []string
-	Concurrency int
+	ArchivePath      string
+	Files            []string
+	Concurrency      int
+	Method           string
+	CompressionLevel int // ranges from 1 (best speed) to 9 (best compression). Zero uses the default level.
+	StoreCompressed  bool
+	StoredExtensions []string // extensions of files to store when StoreCompressed is set. Defaults to DefaultStoredExtensions.
+	SampleCompressed bool     // stores files whose sampled contents barely compress
+	StoreSymlinks    bool
+	Include          []string
+	Exclude          []string
+	IgnoreFiles      []string          // names of ignore files, such as .gitignore, to honor while walking directories
+	Update           bool              // update the existing archive at ArchivePath, only compressing new or modified files
+	Sync             bool              // like Update, but also removes the entries of files that no longer exist
+	Password         string            // encrypt files with the password, using AES encryption
+	KeySize          int               // size of AES keys in bits: 128, 192 or 256. Zero uses 256-bit keys.
+	ZipCrypto        bool              // encrypt files using the weak ZipCrypto instead of AES, for tools which don't support AES
+	VolumeSize       int64             // split the archive into volumes of at most VolumeSize bytes. Zero doesn't split it.
+	Ordered          bool              // write entries in the order of Files and the order in which directories are walked
+	Reproducible     bool              // write entries sorted by name, with clamped modification times and normalized permissions
+	SourceDate       time.Time         // modification times of reproducible archives are clamped to. Zero uses DefaultSourceDate.
+	FileTimes        bool              // store access and creation times of files alongside their modification times
+	Comment          *string           // comment of the archive. Nil keeps the comment of updated archives.
+	EntryComments    map[string]string // comments of entries, by the names of entries
+	Progress         func(Progress)    // called with the progress of archiving, such as ProgressBar.Update
+	Report           func(Report)      // called with the report of the archive, listing every entry, once it's written
+	DryRun           io.Writer         // when set, the entries that would be archived are listed to it, rather than archiving files
+	Prefix           string            // directory under which entries are placed
+	RootNames        map[string]string // names of the entries of Files, by their paths
 }
 
 func (a *ArchiverCLI) Archive(ctx context.Context) error {
+	options, err := a.options()
+	if err != nil {
+		return fmt.Errorf("archiver options: %w", err)
+	}
+
+	if a.DryRun != nil {
+		if a.Update || a.Sync {
+			return errors.New("can't do a dry run of an update")
+		}
+		return a.dryRun(ctx, options)
+	}
+
+	if a.WritesToStdout() {
+		if a.Update || a.Sync {
+			return errors.New("can't update an archive written to stdout")
+		}
+		if a.VolumeSize > 0 {
+			return errors.New("can't split an archive written to stdout")
+		}
+		return a.write(ctx, os.Stdout, options)
+	}
+
+	if a.VolumeSize > 0 {
+		if a.Update || a.Sync {
+			return errors.New("can't update a split archive")
+		}
+		return a.split(ctx, options)
+	}
+
+	if a.Update || a.Sync {
+		_, err := os.Stat(a.ArchivePath)
+		if err == nil {
+			return a.update(ctx, options)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("stat archive at %q: %w", a.ArchivePath, err)
+		}
+	}
+
 	archive, err := os.Create(a.ArchivePath)
 	if err != nil {
 		return fmt.Errorf("create archive at %q: %w", a.ArchivePath, err)
 	}
 	defer archive.Close()
 
-	archiver, err := NewArchiver(archive, ArchiverConcurrency(a.Concurrency))
+	return a.write(ctx, archive, options)
+}
+
+// dryRun lists the entries that would be archived to DryRun, with their sizes, followed by their total size
+// and number, as listed by unzip -l. The archive isn't created.
+func (a *ArchiverCLI) dryRun(ctx context.Context, options []archiverOption) error {
+	var listErr error
+	var total int64
+	var entries int
+	list := ArchiverDryRun(func(name string, info fs.FileInfo) {
+		var size int64
+		if info.Mode().IsRegular() {
+			size = info.Size()
+		}
+
+		total += size
+		entries++
+		if _, err := fmt.Fprintf(a.DryRun, "%12d  %s\n", size, name); err != nil && listErr == nil {
+			listErr = fmt.Errorf("list entry %q: %w", name, err)
+		}
+	})
+
+	// the archive is named, so that it isn't listed if it would be within the archived files
+	var archive io.Writer = io.Discard
+	if !a.WritesToStdout() {
+		archive = &namedDiscard{name: a.ArchivePath}
+	}
+
+	if err := a.write(ctx, archive, append(options, list)); err != nil {
+		return err
+	}
+	if listErr != nil {
+		return listErr
+	}
+
+	if _, err := fmt.Fprintf(a.DryRun, "%12d  %d entries\n", total, entries); err != nil {
+		return fmt.Errorf("list total: %w", err)
+	}
+
+	return nil
+}
+
+// A namedDiscard discards everything written to it, while having the name of the file it stands in for.
+type namedDiscard struct {
+	name string
+}
+
+func (d *namedDiscard) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (d *namedDiscard) Name() string {
+	return d.name
+}
+
+// update updates the existing archive at ArchivePath.
+func (a *ArchiverCLI) update(ctx context.Context, options []archiverOption) error {
+	update := ArchiverUpdate(a.ArchivePath)
+	if a.Sync {
+		update = ArchiverSync(a.ArchivePath)
+	}
+
+	return replaceArchive(a.ArchivePath, func(archive io.Writer) error {
+		return a.write(ctx, archive, append(options, update))
+	})
+}
+
+// split writes the archive as a split archive, removing its volumes if archiving fails.
+func (a *ArchiverCLI) split(ctx context.Context, options []archiverOption) error {
+	archive, err := NewSplitWriter(a.ArchivePath, a.VolumeSize)
+	if err != nil {
+		return fmt.Errorf("create split archive at %q: %w", a.ArchivePath, err)
+	}
+
+	if err = a.write(ctx, archive, options); err != nil {
+		archive.remove()
+		return err
+	}
+
+	if err = archive.Close(); err != nil {
+		archive.remove()
+		return fmt.Errorf("close split archive: %w", err)
+	}
+
+	return nil
+}
+
+func (a *ArchiverCLI) write(ctx context.Context, archive io.Writer, options []archiverOption) error {
+	archiver, err := NewArchiver(archive, options...)
 	if err != nil {
 		return fmt.Errorf("create archiver: %w", err)
 	}
-	defer archiver.Close()
 
 	err = archiver.Archive(ctx, a.Files)
 	if err != nil {
+		archiver.Close()
 		return fmt.Errorf("archive files: %w", err)
 	}
 
+	if err = archiver.Close(); err != nil {
+		return fmt.Errorf("close archiver: %w", err)
+	}
+
+	if a.Report != nil {
+		a.Report(archiver.Report())
+	}
+
 	return nil
 }
 
-type ExtractorCLI struct {
+// WritesToStdout reports whether the archive is written to stdout, which is the case when ArchivePath is "-".
+func (a *ArchiverCLI) WritesToStdout() bool {
+	return a.ArchivePath == stdoutPath
+}
+
+func (a *ArchiverCLI) options() ([]archiverOption, error) {
+	options := []archiverOption{ArchiverConcurrency(a.Concurrency)}
+
+	if a.Method != "" {
+		method, ok := methods[a.Method]
+		if !ok {
+			return nil, fmt.Errorf("unknown compression method %q", a.Method)
+		}
+		options = append(options, ArchiverMethod(method))
+	}
+
+	if a.CompressionLevel != 0 {
+		options = append(options, ArchiverCompressionLevel(a.CompressionLevel))
+	}
+
+	if a.StoreCompressed {
+		options = append(options, ArchiverStoreCompressed(a.StoredExtensions...))
+	}
+
+	if a.SampleCompressed {
+		options = append(options, ArchiverSampleCompressed())
+	}
+
+	if a.StoreSymlinks {
+		options = append(options, ArchiverStoreSymlinks())
+	}
+
+	if len(a.Include) > 0 {
+		options = append(options, ArchiverInclude(a.Include...))
+	}
+
+	if len(a.Exclude) > 0 {
+		options = append(options, ArchiverExclude(a.Exclude...))
+	}
+
+	if len(a.IgnoreFiles) > 0 {
+		options = append(options, ArchiverIgnoreFiles(a.IgnoreFiles...))
+	}
+
+	if a.Password != "" && a.ZipCrypto {
+		options = append(options, ArchiverZipCrypto(a.Password))
+	} else if a.Password != "" {
+		keySize := a.KeySize
+		if keySize == 0 {
+			keySize = defaultAESKeySize
+		}
+		options = append(options, ArchiverEncryption(a.Password, keySize))
+	}
+
+	if a.Ordered {
+		options = append(options, ArchiverOrdered())
+	}
+
+	if a.Reproducible {
+		options = append(options, ArchiverReproducible(a.SourceDate))
+	}
+
+	if a.FileTimes {
+		options = append(options, ArchiverFileTimes())
+	}
+
+	if a.Comment != nil {
+		options = append(options, ArchiverComment(*a.Comment))
+	}
+
+	if a.Progress != nil {
+		options = append(options, ArchiverProgress(a.Progress))
+	}
+
+	if a.Report != nil {
+		options = append(options, ArchiverReport())
+	}
+
+	if a.Prefix != "" {
+		options = append(options, ArchiverPrefix(a.Prefix))
+	}
+
+	if len(a.RootNames) > 0 {
+		options = append(options, ArchiverRootNames(a.RootNames))
+	}
+
+	if a.EntryComments != nil {
+		options = append(options, ArchiverEntryComments(func(name string) string {
+			return a.EntryComments[name]
+		}))
+	}
+
+	return options, nil
+}
+
+// replaceArchive replaces the existing archive at path with the archive written by write. The archive is written
+// to a temporary file alongside the existing archive, which is left untouched if write returns an error.
+func replaceArchive(path string, write func(archive io.Writer) error) (err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("stat archive at %q: %w", path, err)
+	}
+
+	archive, err := os.CreateTemp(filepath.Dir(path), ".pzip-update-*")
+	if err != nil {
+		return fmt.Errorf("create temporary archive: %w", err)
+	}
+	defer func() {
+		archive.Close()
+		if err != nil {
+			os.Remove(archive.Name())
+		}
+	}()
+
+	if err = archive.Chmod(info.Mode().Perm()); err != nil {
+		return fmt.Errorf("chmod %q: %w", archive.Name(), err)
+	}
+
+	if err = write(archive); err != nil {
+		return err
+	}
+
+	if err = archive.Close(); err != nil {
+		return fmt.Errorf("close %q: %w", archive.Name(), err)
+	}
+
+	if err = os.Rename(archive.Name(), path); err != nil {
+		return fmt.Errorf("replace archive at %q: %w", path, err)
+	}
+
+	return nil
+}
+
+type DeleterCLI struct {
 	ArchivePath string
-	OutputDir   string
-	Concurrency int
+	Patterns    []string
+}
+
+// Delete deletes the entries matching Patterns from the archive at ArchivePath. An error is returned, leaving
+// the archive untouched, if no entries match.
+func (d *DeleterCLI) Delete(ctx context.Context) error {
+	return replaceArchive(d.ArchivePath, func(archive io.Writer) error {
+		r, err := zip.OpenReader(d.ArchivePath)
+		if err != nil {
+			return fmt.Errorf("open archive %q: %w", d.ArchivePath, err)
+		}
+		defer r.Close()
+
+		deleted, err := Delete(ctx, archive, &r.Reader, d.Patterns...)
+		if err != nil {
+			return fmt.Errorf("delete entries of %q: %w", d.ArchivePath, err)
+		}
+
+		if len(deleted) == 0 {
+			return fmt.Errorf("no entries of %q match %q", d.ArchivePath, d.Patterns)
+		}
+
+		return nil
+	})
+}
+
+type ExtractorCLI struct {
+	ArchivePath    string
+	OutputDir      string
+	Concurrency    int
+	Password       string                 // password of encrypted files
+	PromptPassword func() (string, error) // called for a password if the archive has encrypted files and Password is empty
+	RestoreOwners  bool                   // restore the owners of files when running as root
+	Comments       io.Writer              // when set, the comments of the archive are written to it rather than extracting files
+	Progress       func(Progress)         // called with the progress of extraction, such as ProgressBar.Update
 }
 
 func (e *ExtractorCLI) Extract(ctx context.Context) error {
-	extractor, err := NewExtractor(e.OutputDir, ExtractorConcurrency(e.Concurrency))
+	if e.Comments != nil {
+		return WriteComments(e.Comments, e.ArchivePath)
+	}
+
+	options := []extractorOption{ExtractorConcurrency(e.Concurrency)}
+
+	password, err := e.password()
+	if err != nil {
+		return fmt.Errorf("password: %w", err)
+	}
+	if password != "" {
+		options = append(options, ExtractorPassword(password))
+	}
+
+	if e.RestoreOwners {
+		options = append(options, ExtractorOwnership())
+	}
+
+	if e.Progress != nil {
+		options = append(options, ExtractorProgress(e.Progress))
+	}
+
+	extractor, err := NewExtractor(e.OutputDir, options...)
 	if err != nil {
 		return fmt.Errorf("new extractor: %w", err)
 	}
@@ -53,3 +423,17 @@ func (e *ExtractorCLI) Extract(ctx context.Context) error {
 
 	return nil
 }
+
+// password returns the password of the archive, prompting for it if needed.
+func (e *ExtractorCLI) password() (string, error) {
+	if e.Password != "" || e.PromptPassword == nil {
+		return e.Password, nil
+	}
+
+	encrypted, err := hasEncryptedFiles(e.ArchivePath)
+	if err != nil || !encrypted {
+		return "", err
+	}
+
+	return e.PromptPassword()
+}
@@ -1,7 +1,10 @@
 package pzip_test
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
+	"crypto/rand"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -25,7 +28,7 @@ func TestArchiverCLI(t *testing.T) {
 		archivePath := "testdata/archive.zip"
 		defer os.RemoveAll(archivePath)
 
-		cli := pzip.ArchiverCLI{archivePath, files, runtime.GOMAXPROCS(0)}
+		cli := pzip.ArchiverCLI{ArchivePath: archivePath, Files: files, Concurrency: runtime.GOMAXPROCS(0)}
 		err := cli.Archive(context.Background())
 		assert.NoError(t, err)
 
@@ -34,6 +37,162 @@ func TestArchiverCLI(t *testing.T) {
 
 		assert.Equal(t, 5, len(archiveReader.File))
 	})
+
+	t.Run("archives files with the given method and compression level", func(t *testing.T) {
+		archivePath := "testdata/archive.zip"
+		defer os.RemoveAll(archivePath)
+
+		cli := pzip.ArchiverCLI{ArchivePath: archivePath, Files: []string{"testdata/hello.txt"}, Concurrency: 1, Method: "deflate", CompressionLevel: 9}
+		err := cli.Archive(context.Background())
+		assert.NoError(t, err)
+
+		archiveReader := testutils.GetArchiveReader(t, archivePath)
+		defer archiveReader.Close()
+
+		assert.Equal(t, zip.Deflate, archiveReader.File[0].Method)
+	})
+
+	t.Run("returns an error for an unknown method", func(t *testing.T) {
+		archivePath := "testdata/archive.zip"
+		defer os.RemoveAll(archivePath)
+
+		cli := pzip.ArchiverCLI{ArchivePath: archivePath, Files: []string{"testdata/hello.txt"}, Concurrency: 1, Method: "lzma"}
+		err := cli.Archive(context.Background())
+		assert.Error(t, err)
+	})
+
+	t.Run("updates an existing archive", func(t *testing.T) {
+		archivePath := filepath.Join(t.TempDir(), "archive.zip")
+
+		cli := pzip.ArchiverCLI{ArchivePath: archivePath, Files: []string{"testdata/hello.txt"}, Concurrency: 1}
+		err := cli.Archive(context.Background())
+		assert.NoError(t, err)
+
+		cli = pzip.ArchiverCLI{ArchivePath: archivePath, Files: []string{"testdata/hello.md"}, Concurrency: 1, Update: true}
+		err = cli.Archive(context.Background())
+		assert.NoError(t, err)
+
+		archiveReader := testutils.GetArchiveReader(t, archivePath)
+		defer archiveReader.Close()
+
+		assert.Equal(t, 2, len(archiveReader.File))
+		testutils.AssertArchiveContainsFile(t, archiveReader.File, "hello.txt")
+		testutils.AssertArchiveContainsFile(t, archiveReader.File, "hello.md")
+
+		leftovers, err := filepath.Glob(filepath.Join(filepath.Dir(archivePath), ".pzip-update-*"))
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(leftovers))
+	})
+
+	t.Run("syncs an existing archive", func(t *testing.T) {
+		archivePath := filepath.Join(t.TempDir(), "archive.zip")
+
+		cli := pzip.ArchiverCLI{ArchivePath: archivePath, Files: []string{"testdata/hello.txt", "testdata/hello.md"}, Concurrency: 1}
+		err := cli.Archive(context.Background())
+		assert.NoError(t, err)
+
+		cli = pzip.ArchiverCLI{ArchivePath: archivePath, Files: []string{"testdata/hello.md"}, Concurrency: 1, Sync: true}
+		err = cli.Archive(context.Background())
+		assert.NoError(t, err)
+
+		archiveReader := testutils.GetArchiveReader(t, archivePath)
+		defer archiveReader.Close()
+
+		assert.Equal(t, 1, len(archiveReader.File))
+		assert.Equal(t, "hello.md", archiveReader.File[0].Name)
+	})
+
+	t.Run("splits an archive into volumes which are extracted", func(t *testing.T) {
+		dir := t.TempDir()
+		contents := make([]byte, 3*pzip.MinVolumeSize)
+		_, err := rand.Read(contents)
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "random.bin"), contents, 0644))
+
+		archivePath := filepath.Join(t.TempDir(), "archive.zip")
+		cli := pzip.ArchiverCLI{ArchivePath: archivePath, Files: []string{dir}, Concurrency: 1, VolumeSize: pzip.MinVolumeSize}
+		err = cli.Archive(context.Background())
+		assert.NoError(t, err)
+
+		for _, volume := range []string{"archive.z01", "archive.z02", "archive.z03", "archive.zip"} {
+			_, err = os.Stat(filepath.Join(filepath.Dir(archivePath), volume))
+			assert.NoError(t, err)
+		}
+
+		outputDir := t.TempDir()
+		extractor := pzip.ExtractorCLI{ArchivePath: archivePath, OutputDir: outputDir, Concurrency: 1}
+		err = extractor.Extract(context.Background())
+		assert.NoError(t, err)
+
+		got, err := os.ReadFile(filepath.Join(outputDir, filepath.Base(dir), "random.bin"))
+		assert.NoError(t, err)
+		assert.Equal(t, contents, got)
+	})
+
+	t.Run("lists the entries of a dry run without creating the archive", func(t *testing.T) {
+		archivePath := filepath.Join(t.TempDir(), "archive.zip")
+
+		var out bytes.Buffer
+		cli := pzip.ArchiverCLI{ArchivePath: archivePath, Files: []string{"testdata/hello.txt"}, Concurrency: 1, DryRun: &out}
+		err := cli.Archive(context.Background())
+		assert.NoError(t, err)
+
+		assert.Equal(t, "          14  hello.txt\n          14  1 entries\n", out.String())
+		_, err = os.Stat(archivePath)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("returns an error for a dry run of an update", func(t *testing.T) {
+		archivePath := filepath.Join(t.TempDir(), "archive.zip")
+
+		var out bytes.Buffer
+		cli := pzip.ArchiverCLI{ArchivePath: archivePath, Files: []string{"testdata/hello.txt"}, Concurrency: 1, Update: true, DryRun: &out}
+		err := cli.Archive(context.Background())
+		assert.Error(t, err)
+
+		cli = pzip.ArchiverCLI{ArchivePath: archivePath, Files: []string{"testdata/hello.txt"}, Concurrency: 1, Sync: true, DryRun: &out}
+		err = cli.Archive(context.Background())
+		assert.Error(t, err)
+		assert.Equal(t, "", out.String())
+	})
+}
+
+func TestDeleterCLI(t *testing.T) {
+	t.Run("deletes matching entries from an archive", func(t *testing.T) {
+		archivePath := filepath.Join(t.TempDir(), "archive.zip")
+
+		archiver := pzip.ArchiverCLI{ArchivePath: archivePath, Files: []string{"testdata/hello"}, Concurrency: 1}
+		err := archiver.Archive(context.Background())
+		assert.NoError(t, err)
+
+		cli := pzip.DeleterCLI{ArchivePath: archivePath, Patterns: []string{"hello/nested/**"}}
+		err = cli.Delete(context.Background())
+		assert.NoError(t, err)
+
+		archiveReader := testutils.GetArchiveReader(t, archivePath)
+		defer archiveReader.Close()
+
+		assert.Equal(t, 2, len(archiveReader.File))
+		testutils.AssertArchiveContainsFile(t, archiveReader.File, "hello/")
+		testutils.AssertArchiveContainsFile(t, archiveReader.File, "hello/hello.txt")
+	})
+
+	t.Run("returns an error and leaves the archive untouched when no entries match", func(t *testing.T) {
+		archivePath := filepath.Join(t.TempDir(), "archive.zip")
+
+		archiver := pzip.ArchiverCLI{ArchivePath: archivePath, Files: []string{"testdata/hello"}, Concurrency: 1}
+		err := archiver.Archive(context.Background())
+		assert.NoError(t, err)
+
+		cli := pzip.DeleterCLI{ArchivePath: archivePath, Patterns: []string{"**/*.log"}}
+		err = cli.Delete(context.Background())
+		assert.Error(t, err)
+
+		archiveReader := testutils.GetArchiveReader(t, archivePath)
+		defer archiveReader.Close()
+
+		assert.Equal(t, 4, len(archiveReader.File))
+	})
 }
 
 func TestExtractorCLI(t *testing.T) {
@@ -46,12 +205,51 @@ func TestExtractorCLI(t *testing.T) {
 		extractedDirPath := filepath.Join(outputDirPath, testArchiveDirectoryName)
 		defer os.RemoveAll(outputDirPath)
 
-		cli := pzip.ExtractorCLI{archivePath, outputDirPath, runtime.GOMAXPROCS(0)}
+		cli := pzip.ExtractorCLI{ArchivePath: archivePath, OutputDir: outputDirPath, Concurrency: runtime.GOMAXPROCS(0)}
 		err = cli.Extract(context.Background())
 		assert.NoError(t, err)
 
 		assert.Equal(t, 3, len(testutils.GetAllFiles(t, extractedDirPath)))
 	})
+
+	t.Run("prompts for the password of an encrypted archive", func(t *testing.T) {
+		archivePath := filepath.Join(t.TempDir(), "archive.zip")
+
+		archiver := pzip.ArchiverCLI{ArchivePath: archivePath, Files: []string{"testdata/hello"}, Concurrency: 1, Password: "secret"}
+		err := archiver.Archive(context.Background())
+		assert.NoError(t, err)
+
+		prompted := false
+		outputDirPath := t.TempDir()
+		cli := pzip.ExtractorCLI{
+			ArchivePath: archivePath,
+			OutputDir:   outputDirPath,
+			Concurrency: 1,
+			PromptPassword: func() (string, error) {
+				prompted = true
+				return "secret", nil
+			},
+		}
+		err = cli.Extract(context.Background())
+		assert.NoError(t, err)
+
+		assert.True(t, prompted)
+		assert.Equal(t, 3, len(testutils.GetAllFiles(t, filepath.Join(outputDirPath, testArchiveDirectoryName))))
+	})
+
+	t.Run("doesn't prompt for a password when the archive isn't encrypted", func(t *testing.T) {
+		cli := pzip.ExtractorCLI{
+			ArchivePath: "testdata/test.zip",
+			OutputDir:   t.TempDir(),
+			Concurrency: 1,
+			PromptPassword: func() (string, error) {
+				t.Fatal("prompted for a password")
+				return "", nil
+			},
+		}
+		err := cli.Extract(context.Background())
+		assert.NoError(t, err)
+	})
 }
 
 // BenchmarkArchiverCLI benchmarks the archiving of a file/directory, referenced by benchmarkDir in the benchmarkRoot directory
@@ -59,7 +257,7 @@ func BenchmarkArchiverCLI(b *testing.B) {
 	outputDirPath := filepath.Join(benchmarkRoot, benchmarkDir)
 	archivePath := filepath.Join(benchmarkRoot, benchmarkDir+".zip")
 
-	cli := pzip.ArchiverCLI{archivePath, []string{outputDirPath}, runtime.GOMAXPROCS(0)}
+	cli := pzip.ArchiverCLI{ArchivePath: archivePath, Files: []string{outputDirPath}, Concurrency: runtime.GOMAXPROCS(0)}
 
 	b.ReportAllocs()
 	b.ResetTimer()
@@ -75,7 +273,7 @@ func BenchmarkArchiverCLI(b *testing.B) {
 func BenchmarkExtractorCLI(b *testing.B) {
 	archivePath := filepath.Join(benchmarkRoot, benchmarkArchive)
 
-	cli := pzip.ExtractorCLI{archivePath, benchmarkRoot, runtime.GOMAXPROCS(0)}
+	cli := pzip.ExtractorCLI{ArchivePath: archivePath, OutputDir: benchmarkRoot, Concurrency: runtime.GOMAXPROCS(0)}
 
 	b.ReportAllocs()
 	b.ResetTimer()
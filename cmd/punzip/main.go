@@ -10,10 +10,27 @@ import (
 	"runtime"
 
 	"github.com/ybirader/pzip"
+	"golang.org/x/term"
 )
 
 const description = "punzip is a tool for extracting files concurrently."
 
+// promptPassword reads the password of an encrypted archive from the terminal.
+func promptPassword() (string, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("archive is encrypted: use -P or set %s", pzip.PasswordEnv)
+	}
+
+	fmt.Fprint(os.Stderr, "Enter password: ")
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("read password: %w", err)
+	}
+
+	return string(password), nil
+}
+
 func main() {
 	flag.Usage = func() {
 		fmt.Fprintln(os.Stderr, description)
@@ -23,8 +40,14 @@ func main() {
 
 	var concurrency int
 	var outputDir string
+	var password string
+	var restoreOwners bool
+	var printComments bool
 	flag.IntVar(&concurrency, "concurrency", runtime.GOMAXPROCS(0), "allow up to n compression routines")
 	flag.StringVar(&outputDir, "d", ".", "extract files into the specified directory")
+	flag.StringVar(&password, "P", "", "decrypt encrypted files using the given password. Defaults to "+pzip.PasswordEnv+", otherwise prompts for one")
+	flag.BoolVar(&restoreOwners, "X", false, "restore the user and group owning files when running as root")
+	flag.BoolVar(&printComments, "z", false, "print the comments of the archive and its entries, without extracting files")
 
 	flag.Parse()
 
@@ -35,7 +58,26 @@ func main() {
 		return
 	}
 
-	cli := pzip.ExtractorCLI{ArchivePath: args[0], OutputDir: outputDir, Concurrency: concurrency}
+	if password == "" {
+		password = os.Getenv(pzip.PasswordEnv)
+	}
+
+	cli := pzip.ExtractorCLI{
+		ArchivePath:    args[0],
+		OutputDir:      outputDir,
+		Concurrency:    concurrency,
+		Password:       password,
+		PromptPassword: promptPassword,
+		RestoreOwners:  restoreOwners,
+	}
+	// progress is only shown on a terminal, so that it doesn't end up in logs
+	var progressBar *pzip.ProgressBar
+	if printComments {
+		cli.Comments = os.Stdout
+	} else if term.IsTerminal(int(os.Stderr.Fd())) {
+		progressBar = pzip.NewProgressBar(os.Stderr)
+		cli.Progress = progressBar.Update
+	}
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	go func() {
 		<-ctx.Done()
@@ -43,6 +85,9 @@ func main() {
 	}()
 
 	err := cli.Extract(ctx)
+	if progressBar != nil {
+		progressBar.Finish()
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -1,19 +1,181 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"runtime"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ybirader/pzip"
+	"golang.org/x/term"
 )
 
 const description = "pzip is a tool for archiving files concurrently."
 
+const defaultLevel = -1
+
+// levelFlag is a boolean flag which sets the compression level to its value when given,
+// like the -0 to -9 flags of zip.
+type levelFlag struct {
+	level *int
+	value int
+}
+
+func (l *levelFlag) String() string {
+	return "false"
+}
+
+func (l *levelFlag) Set(s string) error {
+	set, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+
+	if set {
+		*l.level = l.value
+	}
+	return nil
+}
+
+func (l *levelFlag) IsBoolFlag() bool {
+	return true
+}
+
+// patternsFlag collects the patterns of a flag that may be given multiple times.
+type patternsFlag []string
+
+func (p *patternsFlag) String() string {
+	return strings.Join(*p, ", ")
+}
+
+func (p *patternsFlag) Set(s string) error {
+	*p = append(*p, s)
+	return nil
+}
+
+// sizeFlag is a size in bytes, given as a number followed by a unit of k, m, g or t, such as 2g.
+// Sizes without a unit are in megabytes, as for zip.
+type sizeFlag int64
+
+func (s *sizeFlag) String() string {
+	return strconv.FormatInt(int64(*s), 10)
+}
+
+func (s *sizeFlag) Set(value string) error {
+	units := map[byte]int64{'k': 1 << 10, 'm': 1 << 20, 'g': 1 << 30, 't': 1 << 40}
+
+	unit := int64(1 << 20)
+	if n := len(value); n > 0 {
+		if u, ok := units[strings.ToLower(value)[n-1]]; ok {
+			unit = u
+			value = value[:n-1]
+		}
+	}
+
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size <= 0 {
+		return fmt.Errorf("invalid size %q", value)
+	}
+
+	*s = sizeFlag(size * unit)
+	return nil
+}
+
+// promptPassword reads a password from the terminal, asking for it twice to catch typos.
+func promptPassword() (string, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("no password given: use -P or set %s", pzip.PasswordEnv)
+	}
+
+	fmt.Fprint(os.Stderr, "Enter password: ")
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("read password: %w", err)
+	}
+
+	fmt.Fprint(os.Stderr, "Verify password: ")
+	verified, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("read password: %w", err)
+	}
+
+	if string(password) != string(verified) {
+		return "", errors.New("passwords don't match")
+	}
+
+	return string(password), nil
+}
+
+// readComment reads an archive comment from standard input, like zip -z, up to a line holding only a period
+// or the end of input. The comment is prompted for when standard input is a terminal.
+func readComment() (string, error) {
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprintln(os.Stderr, "Enter new zip file comment (end with .):")
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() && scanner.Text() != "." {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("read comment: %w", err)
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// loadComment returns the archive comment given with -comment, read from the file given with -commentfile
+// or read from standard input with -z. It returns nil if no comment is given.
+func loadComment(comment, commentFile string, readStdin bool) (*string, error) {
+	if (readStdin && commentFile != "") || (readStdin && comment != "") || (commentFile != "" && comment != "") {
+		return nil, errors.New("only one of -z, -comment and -commentfile can be given")
+	}
+
+	switch {
+	case readStdin:
+		comment, err := readComment()
+		return &comment, err
+	case commentFile != "":
+		contents, err := os.ReadFile(commentFile)
+		if err != nil {
+			return nil, fmt.Errorf("read comment file: %w", err)
+		}
+		comment := strings.TrimSuffix(string(contents), "\n")
+		return &comment, nil
+	case comment != "":
+		return &comment, nil
+	}
+
+	return nil, nil
+}
+
+// loadEntryComments reads the comments of entries from the file at path, if given.
+func loadEntryComments(path string) (map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open entry comments: %w", err)
+	}
+	defer file.Close()
+
+	return pzip.ReadEntryComments(file)
+}
+
 func main() {
 	flag.Usage = func() {
 		fmt.Fprintln(os.Stderr, description)
@@ -22,7 +184,65 @@ func main() {
 	}
 
 	var concurrency int
+	var method string
+	var storeCompressed bool
+	var storedSuffixes string
+	var storeSymlinks bool
+	var include, exclude patternsFlag
+	var gitignore bool
+	var ignoreFiles patternsFlag
+	var update, sync bool
+	var del bool
+	var encrypt bool
+	var password string
+	var keySize int
+	var zipCrypto bool
+	var volumeSize sizeFlag
+	var ordered, reproducible bool
+	var fileTimes bool
+	var readStdinComment bool
+	var comment, commentFile, entryCommentsFile string
+	var reportFormat string
+	var dryRun bool
+	var prefix string
+	var rootNames patternsFlag
 	flag.IntVar(&concurrency, "concurrency", runtime.GOMAXPROCS(0), "allow up to n compression routines")
+	flag.StringVar(&method, "method", "deflate", "compress files using the specified method: store, deflate or zstd")
+	flag.BoolVar(&storeCompressed, "autostore", false, "store files which are already compressed, such as .jpg, .mp4 and .zip files, or whose contents barely compress, instead of compressing them")
+	flag.StringVar(&storedSuffixes, "n", "", "store files with the given colon-separated suffixes, e.g. .jpg:.zip, instead of compressing them")
+	flag.BoolVar(&storeSymlinks, "y", false, "store symbolic links as links, rather than the files they refer to")
+	flag.BoolVar(&storeSymlinks, "symlinks", false, "store symbolic links as links, the same as -y")
+	flag.Var(&include, "i", "only archive files matching the glob pattern, e.g. '**/*.go'. May be repeated")
+	flag.Var(&exclude, "x", "skip files and directories matching the glob pattern, e.g. '**/node_modules'. May be repeated")
+	flag.BoolVar(&gitignore, "gitignore", false, "skip files ignored by .gitignore and .pzipignore files")
+	flag.Var(&ignoreFiles, "ignorefile", "skip files ignored by ignore files with the given name, e.g. .dockerignore. May be repeated")
+	flag.BoolVar(&update, "u", false, "update the existing archive, only compressing new or modified files")
+	flag.BoolVar(&del, "d", false, "delete the entries matching the given glob patterns from the archive, e.g. pzip -d archive.zip '**/*.log'")
+	flag.BoolVar(&encrypt, "e", false, "encrypt files, using the password in "+pzip.PasswordEnv+" or prompting for one")
+	flag.StringVar(&password, "P", "", "password to encrypt files with. Visible to other users of the system")
+	flag.IntVar(&keySize, "aes", 256, "size of AES encryption keys in bits: 128, 192 or 256")
+	flag.BoolVar(&zipCrypto, "zipcrypto", false, "encrypt files with the legacy ZipCrypto instead of AES. ZipCrypto is weak: only use it for tools which don't support AES")
+	flag.Var(&volumeSize, "s", "split the archive into volumes of at most the given size, e.g. 2g or 100m, named archive.z01, archive.z02, ..., archive.zip")
+	flag.BoolVar(&ordered, "ordered", false, "write entries in the order of the given files and directories, rather than the order in which they finish compressing")
+	flag.BoolVar(&reproducible, "reproducible", false, "write a byte-for-byte reproducible archive: entries sorted by name, modification times clamped to "+pzip.SourceDateEpochEnv+" (default 1980-01-01) and permissions normalized")
+	flag.BoolVar(&fileTimes, "filetimes", false, "store the times of files with a precision of 100ns, including access and creation times where available")
+	flag.BoolVar(&readStdinComment, "z", false, "add a comment to the archive, read from standard input up to a line holding only '.'")
+	flag.StringVar(&comment, "comment", "", "add the given comment to the archive")
+	flag.StringVar(&commentFile, "commentfile", "", "add the contents of the given file as the comment of the archive")
+	flag.StringVar(&entryCommentsFile, "entrycomments", "", "add comments to entries from the given file, holding the name of an entry and its comment separated by a tab on each line")
+	flag.StringVar(&reportFormat, "report", "", "write a report of the archive, listing the size, compressed size and compression time of each entry, to stdout in the given format: json")
+	flag.BoolVar(&dryRun, "dryrun", false, "list the entries that would be archived, with their sizes, without reading files or creating the archive")
+	flag.StringVar(&prefix, "prefix", "", "place entries under the given directory, e.g. myapp-1.2")
+	flag.Var(&rootNames, "map", "name the entries of a given file or directory after dst rather than its own name, given as src=dst, e.g. build/out=bin. An empty dst places the contents of a directory at the top of the archive. May be repeated")
+	flag.BoolVar(&sync, "FS", false, "sync the existing archive with the files, like -u but also removing entries of files that no longer exist")
+
+	level := defaultLevel
+	flag.Var(&levelFlag{&level, 0}, "0", "store files without compression")
+	flag.Var(&levelFlag{&level, 1}, "1", "compress faster")
+	for n := 2; n <= 8; n++ {
+		flag.Var(&levelFlag{&level, n}, strconv.Itoa(n), fmt.Sprintf("compress using level %d", n))
+	}
+	flag.Var(&levelFlag{&level, 9}, "9", "compress better")
 
 	flag.Parse()
 
@@ -36,16 +256,131 @@ func main() {
 		return
 	}
 
-	cli := pzip.ArchiverCLI{ArchivePath: args[0], Files: args[1:], Concurrency: concurrency}
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	go func() {
 		<-ctx.Done()
 		stop()
 	}()
 
-	err := cli.Archive(ctx)
+	if del {
+		cli := pzip.DeleterCLI{ArchivePath: args[0], Patterns: args[1:]}
+		if err := cli.Delete(ctx); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if level == 0 {
+		method = "store"
+	}
+
+	var sourceDate time.Time
+	if epoch := os.Getenv(pzip.SourceDateEpochEnv); reproducible && epoch != "" {
+		seconds, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			log.Fatalf("invalid %s %q", pzip.SourceDateEpochEnv, epoch)
+		}
+		sourceDate = time.Unix(seconds, 0)
+	}
+
+	if encrypt && password == "" {
+		if password = os.Getenv(pzip.PasswordEnv); password == "" {
+			var err error
+			if password, err = promptPassword(); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	archiveComment, err := loadComment(comment, commentFile, readStdinComment)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	entryComments, err := loadEntryComments(entryCommentsFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	roots := make(map[string]string, len(rootNames))
+	for _, mapping := range rootNames {
+		src, dst, ok := strings.Cut(mapping, "=")
+		if !ok {
+			log.Fatalf("invalid mapping %q: expected src=dst", mapping)
+		}
+		roots[src] = dst
+	}
+
+	cli := pzip.ArchiverCLI{
+		ArchivePath:      args[0],
+		Files:            args[1:],
+		Concurrency:      concurrency,
+		Method:           method,
+		CompressionLevel: max(level, 0),
+		StoreCompressed:  storeCompressed || storedSuffixes != "",
+		SampleCompressed: storeCompressed,
+		StoreSymlinks:    storeSymlinks,
+		Include:          include,
+		Exclude:          exclude,
+		Update:           update,
+		Sync:             sync,
+		Password:         password,
+		KeySize:          keySize,
+		ZipCrypto:        zipCrypto,
+		VolumeSize:       int64(volumeSize),
+		Ordered:          ordered,
+		Reproducible:     reproducible,
+		FileTimes:        fileTimes,
+		Comment:          archiveComment,
+		EntryComments:    entryComments,
+		SourceDate:       sourceDate,
+		Prefix:           prefix,
+		RootNames:        roots,
+	}
+	if dryRun {
+		cli.DryRun = os.Stdout
+	}
+
+	if reportFormat != "" {
+		if reportFormat != "json" {
+			log.Fatalf("unknown report format %q", reportFormat)
+		}
+		if cli.WritesToStdout() {
+			log.Fatal("can't write a report to stdout along with the archive")
+		}
+
+		cli.Report = func(report pzip.Report) {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(report); err != nil {
+				log.Printf("write report: %v", err)
+			}
+		}
+	}
+
+	// progress is only shown on a terminal, so that it doesn't end up in logs
+	var progressBar *pzip.ProgressBar
+	if !dryRun && term.IsTerminal(int(os.Stderr.Fd())) {
+		progressBar = pzip.NewProgressBar(os.Stderr)
+		cli.Progress = progressBar.Update
+	}
+	if gitignore {
+		cli.IgnoreFiles = append(cli.IgnoreFiles, pzip.DefaultIgnoreFiles...)
+	}
+	cli.IgnoreFiles = append(cli.IgnoreFiles, ignoreFiles...)
+	if storedSuffixes != "" {
+		cli.StoredExtensions = strings.Split(storedSuffixes, ":")
+	}
+
+	err = cli.Archive(ctx)
+	if progressBar != nil {
+		progressBar.Finish()
+	}
 	if err != nil {
-		os.RemoveAll(cli.ArchivePath)
+		// an archive that was being updated, or wasn't created by a dry run, is left untouched
+		if !cli.WritesToStdout() && !cli.Update && !cli.Sync && !dryRun {
+			os.RemoveAll(cli.ArchivePath)
+		}
 		log.Fatal(err)
 	}
 }
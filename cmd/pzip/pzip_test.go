@@ -1,8 +1,13 @@
 package main_test
 
 import (
+	"archive/zip"
+	"bytes"
+	"io/fs"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/alecthomas/assert/v2"
@@ -39,6 +44,34 @@ func TestPzip(t *testing.T) {
 		assert.Contains(t, out, "pzip error: invalid usage\n")
 	})
 
+	t.Run("writes archive to stdout when archive path is -", func(t *testing.T) {
+		pzip := exec.Command(binPath, "-", dirPath)
+		out, err := pzip.Output()
+		assert.NoError(t, err)
+
+		archiveReader, err := zip.NewReader(bytes.NewReader(out), int64(len(out)))
+		assert.NoError(t, err)
+
+		testutils.AssertArchiveContainsFile(t, archiveReader.File, "hello/hello.txt")
+	})
+
+	t.Run("stores symbolic links as links with -y or -symlinks, following them otherwise", func(t *testing.T) {
+		dir := t.TempDir()
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("hello, world!"), 0644))
+		assert.NoError(t, os.Symlink("hello.txt", filepath.Join(dir, "link.txt")))
+
+		for _, flags := range [][]string{nil, {"-y"}, {"-symlinks"}} {
+			args := append(append(flags, "-"), filepath.Join(dir, "link.txt"))
+			out, err := exec.Command(binPath, args...).Output()
+			assert.NoError(t, err)
+
+			archiveReader, err := zip.NewReader(bytes.NewReader(out), int64(len(out)))
+			assert.NoError(t, err)
+			assert.Equal(t, 1, len(archiveReader.File))
+			assert.Equal(t, flags != nil, archiveReader.File[0].Mode()&fs.ModeSymlink != 0, strings.Join(flags, " "))
+		}
+	})
+
 	t.Run("archives directory", func(t *testing.T) {
 		if testing.Short() {
 			t.Skip()
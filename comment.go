@@ -0,0 +1,68 @@
+package pzip
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ReadEntryComments reads the comments of entries from r, which holds the name of an entry and its comment,
+// separated by a tab, on each line. Names of directories end with a slash. Empty lines are skipped. An error
+// is returned if a line doesn't hold a tab, or if a comment is longer than 65535 bytes.
+func ReadEntryComments(r io.Reader) (map[string]string, error) {
+	comments := make(map[string]string)
+
+	scanner := bufio.NewScanner(r)
+	// lines hold a name and a comment of up to uint16max bytes each, which are longer than the default buffer
+	scanner.Buffer(nil, 2*uint16max+len("\t\r\n"))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		if text == "" {
+			continue
+		}
+
+		name, comment, ok := strings.Cut(text, "\t")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected a name and comment separated by a tab", line)
+		}
+		if len(comment) > uint16max {
+			return nil, fmt.Errorf("line %d: comment of %q of %d bytes is longer than %d bytes", line, name, len(comment), uint16max)
+		}
+		comments[name] = comment
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read entry comments: %w", err)
+	}
+
+	return comments, nil
+}
+
+// WriteComments writes the comment of the archive at archivePath to w, followed by the comments of its
+// entries, each on a line prefixed by the name of the entry. Split archives are read as by Extract.
+func WriteComments(w io.Writer, archivePath string) error {
+	archiveReader, closer, err := openArchive(archivePath)
+	if err != nil {
+		return fmt.Errorf("open archive %q: %w", archivePath, err)
+	}
+	defer closer.Close()
+
+	if archiveReader.Comment != "" {
+		if _, err = fmt.Fprintln(w, archiveReader.Comment); err != nil {
+			return fmt.Errorf("write archive comment: %w", err)
+		}
+	}
+
+	for _, file := range archiveReader.File {
+		if file.Comment == "" {
+			continue
+		}
+
+		if _, err = fmt.Fprintf(w, "%s: %s\n", file.Name, file.Comment); err != nil {
+			return fmt.Errorf("write comment of %q: %w", file.Name, err)
+		}
+	}
+
+	return nil
+}
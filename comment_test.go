@@ -0,0 +1,106 @@
+package pzip
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/ybirader/pzip/internal/testutils"
+)
+
+func TestArchiverComment(t *testing.T) {
+	t.Run("sets the comments of the archive and its entries", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "archive.zip")
+		comments := map[string]string{"hello/": "a directory", "hello.txt": "a greeting"}
+		createArchive(t, path, []string{helloTxtFileFixture, helloDirectoryFixture}, ArchiverComment("release 1.0"),
+			ArchiverEntryComments(func(name string) string {
+				return comments[name]
+			}))
+
+		archiveReader := testutils.GetArchiveReader(t, path)
+		defer archiveReader.Close()
+
+		assert.Equal(t, "release 1.0", archiveReader.Comment)
+		for _, file := range archiveReader.File {
+			assert.Equal(t, comments[file.Name], file.Comment, file.Name)
+		}
+	})
+
+	t.Run("keeps the comment of updated archives unless it's replaced", func(t *testing.T) {
+		existingPath := filepath.Join(t.TempDir(), "existing.zip")
+		createArchive(t, existingPath, []string{helloTxtFileFixture}, ArchiverComment("existing"))
+
+		keptPath := filepath.Join(t.TempDir(), "kept.zip")
+		createArchive(t, keptPath, []string{helloTxtFileFixture}, ArchiverUpdate(existingPath))
+		kept := testutils.GetArchiveReader(t, keptPath)
+		defer kept.Close()
+		assert.Equal(t, "existing", kept.Comment)
+
+		replacedPath := filepath.Join(t.TempDir(), "replaced.zip")
+		createArchive(t, replacedPath, []string{helloTxtFileFixture}, ArchiverComment(""), ArchiverUpdate(existingPath))
+		replaced := testutils.GetArchiveReader(t, replacedPath)
+		defer replaced.Close()
+		assert.Equal(t, "", replaced.Comment)
+	})
+
+	t.Run("returns an error for comments longer than 65535 bytes", func(t *testing.T) {
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		_, err := NewArchiver(archive, ArchiverComment(strings.Repeat("a", 1<<16)))
+		assert.Error(t, err)
+	})
+
+	t.Run("returns an error naming entries with comments longer than 65535 bytes", func(t *testing.T) {
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		archiver, err := NewArchiver(archive, ArchiverEntryComments(func(name string) string {
+			return strings.Repeat("a", 1<<16)
+		}))
+		assert.NoError(t, err)
+		defer archiver.Close()
+
+		err = archiver.Archive(context.Background(), []string{helloTxtFileFixture})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), `"hello.txt"`)
+	})
+}
+
+func TestReadEntryComments(t *testing.T) {
+	t.Run("reads names and comments separated by tabs", func(t *testing.T) {
+		comments, err := ReadEntryComments(strings.NewReader("hello.txt\ta greeting\r\n\nhello/\ta directory\twith a tab\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"hello.txt": "a greeting", "hello/": "a directory\twith a tab"}, comments)
+	})
+
+	t.Run("returns an error for lines without a tab", func(t *testing.T) {
+		_, err := ReadEntryComments(strings.NewReader("hello.txt\ta greeting\nhello/ a directory\n"))
+		assert.Error(t, err)
+	})
+
+	t.Run("returns an error naming entries with comments longer than 65535 bytes", func(t *testing.T) {
+		_, err := ReadEntryComments(strings.NewReader("hello.txt\t" + strings.Repeat("a", 1<<16) + "\n"))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), `"hello.txt"`)
+
+		comments, err := ReadEntryComments(strings.NewReader("hello.txt\t" + strings.Repeat("a", uint16max) + "\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, uint16max, len(comments["hello.txt"]))
+	})
+}
+
+func TestWriteComments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.zip")
+	createArchive(t, path, []string{helloTxtFileFixture}, ArchiverComment("release 1.0"),
+		ArchiverEntryComments(func(name string) string {
+			return "a greeting"
+		}))
+
+	var out bytes.Buffer
+	assert.NoError(t, WriteComments(&out, path))
+	assert.Equal(t, "release 1.0\nhello.txt: a greeting\n", out.String())
+}
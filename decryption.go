@@ -0,0 +1,224 @@
+package pzip
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+
+	"github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/zip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// hasEncryptedFiles reports whether any of the files of the archive at path is encrypted.
+func hasEncryptedFiles(path string) (bool, error) {
+	archive, closer, err := openArchive(path)
+	if err != nil {
+		return false, fmt.Errorf("open archive %q: %w", path, err)
+	}
+	defer closer.Close()
+
+	for _, file := range archive.File {
+		if file.Flags&encryptedFlag != 0 {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// parseAESExtraField returns the version, strength and actual compression method stored in the AES extra field
+// of extra. It returns false if there is no such field.
+func parseAESExtraField(extra []byte) (version uint16, strength byte, method uint16, ok bool) {
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra)
+		size := int(binary.LittleEndian.Uint16(extra[2:]))
+		extra = extra[4:]
+		if size > len(extra) {
+			break
+		}
+
+		if id == aesExtraID && size >= aesExtraSize && string(extra[2:4]) == aesVendorID {
+			return binary.LittleEndian.Uint16(extra), extra[4], binary.LittleEndian.Uint16(extra[5:]), true
+		}
+		extra = extra[size:]
+	}
+
+	return 0, 0, 0, false
+}
+
+// openAES opens the contents of the AES encrypted file, decrypting them with password. The authentication code
+// of the file is checked once its contents have been read, or when the returned reader is closed.
+func openAES(file *zip.File, password []byte) (io.ReadCloser, error) {
+	version, strength, method, ok := parseAESExtraField(file.Extra)
+	if !ok {
+		return nil, errors.New("missing AES extra field")
+	}
+	if strength < 1 || strength > 3 {
+		return nil, fmt.Errorf("unsupported AES strength %d", strength)
+	}
+
+	saltLength := aesSaltLength(strength)
+	overhead := uint64(saltLength + aesVerifierSize + aesMACSize)
+	if file.CompressedSize64 < overhead {
+		return nil, zip.ErrFormat
+	}
+
+	raw, err := file.OpenRaw()
+	if err != nil {
+		return nil, fmt.Errorf("open raw: %w", err)
+	}
+
+	header := make([]byte, saltLength+aesVerifierSize)
+	if _, err = io.ReadFull(raw, header); err != nil {
+		return nil, fmt.Errorf("read encryption header: %w", err)
+	}
+
+	key, macKey, verifier := aesKeys(password, header[:saltLength], strength)
+	if !hmac.Equal(verifier, header[saltLength:]) {
+		return nil, ErrPassword
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("new cipher: %w", err)
+	}
+
+	r := &aesReader{
+		r:      io.LimitReader(raw, int64(file.CompressedSize64-overhead)),
+		raw:    raw,
+		stream: newAESCounter(block),
+		mac:    hmac.New(sha1.New, macKey),
+	}
+
+	decompressed, err := decompress(r, method)
+	if err != nil {
+		return nil, err
+	}
+
+	var rc io.ReadCloser = &authenticatedReader{ReadCloser: decompressed, aes: r}
+
+	if version == aesVersion1 {
+		return &checksumReader{ReadCloser: rc, hash: crc32.NewIEEE(), want: file.CRC32}, nil
+	}
+
+	return rc, nil
+}
+
+// decompress returns a reader of the contents of r, compressed using method.
+func decompress(r io.Reader, method uint16) (io.ReadCloser, error) {
+	switch method {
+	case zip.Store:
+		return io.NopCloser(r), nil
+	case zip.Deflate:
+		return flate.NewReader(r), nil
+	case zstd.ZipMethodWinZip, zstd.ZipMethodPKWare:
+		return zstd.ZipDecompressor()(r), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression method %d", method)
+	}
+}
+
+// aesReader decrypts the encrypted contents read from r, checking the authentication code which follows
+// the contents in raw once all of them have been read.
+type aesReader struct {
+	r        io.Reader
+	raw      io.Reader
+	stream   cipher.Stream
+	mac      hash.Hash
+	verified bool
+	err      error // result of verifying the authentication code
+}
+
+func (a *aesReader) Read(p []byte) (int, error) {
+	n, err := a.r.Read(p)
+	a.mac.Write(p[:n])
+	a.stream.XORKeyStream(p[:n], p[:n])
+
+	if err == io.EOF {
+		if verr := a.verify(); verr != nil {
+			return n, verr
+		}
+	}
+
+	return n, err
+}
+
+// verify checks the authentication code of the contents, once the contents which haven't been read are read.
+// Decompressors stop reading at the end of the compressed stream, so they may not read all of the contents.
+func (a *aesReader) verify() error {
+	if a.verified {
+		return a.err
+	}
+	a.verified = true
+
+	if _, err := io.Copy(a.mac, a.r); err != nil {
+		a.err = fmt.Errorf("read encrypted contents: %w", err)
+		return a.err
+	}
+
+	code := make([]byte, aesMACSize)
+	if _, err := io.ReadFull(a.raw, code); err != nil {
+		a.err = fmt.Errorf("read authentication code: %w", err)
+		return a.err
+	}
+	if !hmac.Equal(code, a.mac.Sum(nil)[:aesMACSize]) {
+		a.err = ErrAuthentication
+	}
+
+	return a.err
+}
+
+// authenticatedReader checks the authentication code of AES encrypted contents once they've been decompressed,
+// fail to decompress, or when it's closed, as the end of the compressed contents isn't the end of the encrypted
+// contents.
+type authenticatedReader struct {
+	io.ReadCloser
+	aes *aesReader
+}
+
+func (r *authenticatedReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	// contents which fail to decompress are most likely tampered with, which the authentication code reports
+	if err != nil {
+		if verr := r.aes.verify(); verr != nil {
+			return n, verr
+		}
+	}
+
+	return n, err
+}
+
+func (r *authenticatedReader) Close() error {
+	err := r.ReadCloser.Close()
+	if verr := r.aes.verify(); verr != nil {
+		return verr
+	}
+
+	return err
+}
+
+// checksumReader checks the CRC-32 of the contents read from the underlying reader once all of them have been read.
+type checksumReader struct {
+	io.ReadCloser
+	hash hash.Hash32
+	want uint32
+}
+
+func (c *checksumReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.hash.Write(p[:n])
+
+	if err == io.EOF && c.hash.Sum32() != c.want {
+		return n, zip.ErrChecksum
+	}
+
+	return n, err
+}
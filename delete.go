@@ -0,0 +1,48 @@
+package pzip
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Delete writes the existing archive r to w without the entries whose names match one of patterns, as done by
+// zip -d. Patterns use doublestar glob syntax, so that "**/*.log" matches log files at any depth. The remaining
+// entries are copied as is, without being decompressed and compressed again, keeping their comments and extra
+// fields, as well as the comment of the archive. It returns the names of the deleted entries. An error is returned
+// if a pattern is malformed or ctx is canceled.
+func Delete(ctx context.Context, w io.Writer, r *zip.Reader, patterns ...string) ([]string, error) {
+	if err := validatePatterns(patterns); err != nil {
+		return nil, err
+	}
+
+	zw := zip.NewWriter(w)
+	if err := zw.SetComment(r.Comment); err != nil {
+		return nil, fmt.Errorf("set comment: %w", err)
+	}
+
+	var deleted []string
+	for _, entry := range r.File {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		isDir := strings.HasSuffix(entry.Name, "/")
+		if matchAny(patterns, strings.TrimSuffix(entry.Name, "/"), isDir) {
+			deleted = append(deleted, entry.Name)
+			continue
+		}
+
+		if err := copyEntry(zw, entry); err != nil {
+			return nil, fmt.Errorf("copy entry %q: %w", entry.Name, err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("close zip writer: %w", err)
+	}
+
+	return deleted, nil
+}
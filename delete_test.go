@@ -0,0 +1,78 @@
+package pzip
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/ybirader/pzip/internal/testutils"
+)
+
+func TestDelete(t *testing.T) {
+	t.Run("copies entries which don't match, keeping comments and extra fields", func(t *testing.T) {
+		existing := createArchiveWithEntries(t, "release notes", []*zip.FileHeader{
+			{Name: "hello/", Method: zip.Store},
+			{Name: "hello/hello.txt", Method: zip.Deflate, Comment: "greeting", Extra: []byte{0xfe, 0xca, 0x02, 0x00, 0x01, 0x02}},
+			{Name: "hello/secret.env", Method: zip.Deflate},
+			{Name: "hello/logs/", Method: zip.Store},
+			{Name: "hello/logs/debug.log", Method: zip.Deflate},
+		})
+
+		var buf bytes.Buffer
+		deleted, err := Delete(context.Background(), &buf, existing, "**/*.env", "hello/logs")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"hello/secret.env", "hello/logs/"}, deleted)
+
+		archiveReader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		assert.NoError(t, err)
+
+		assert.Equal(t, "release notes", archiveReader.Comment)
+		assert.Equal(t, 3, len(archiveReader.File))
+		testutils.AssertArchiveContainsFile(t, archiveReader.File, "hello/")
+		testutils.AssertArchiveContainsFile(t, archiveReader.File, "hello/logs/debug.log")
+
+		hello, found := testutils.Find(archiveReader.File, func(file *zip.File) bool {
+			return file.Name == "hello/hello.txt"
+		})
+		assert.True(t, found)
+		assert.Equal(t, "greeting", hello.Comment)
+		assert.Equal(t, []byte{0xfe, 0xca, 0x02, 0x00, 0x01, 0x02}, hello.Extra)
+		assert.Equal(t, "hello/hello.txt", readArchivedFile(t, hello))
+	})
+
+	t.Run("returns an error for a malformed pattern", func(t *testing.T) {
+		existing := createArchiveWithEntries(t, "", []*zip.FileHeader{{Name: "hello.txt"}})
+
+		var buf bytes.Buffer
+		_, err := Delete(context.Background(), &buf, existing, "[")
+		assert.Error(t, err)
+	})
+}
+
+// createArchiveWithEntries returns a reader of an in-memory archive with the given comment and entries,
+// with each entry containing its own name.
+func createArchiveWithEntries(t testing.TB, comment string, headers []*zip.FileHeader) *zip.Reader {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	assert.NoError(t, w.SetComment(comment))
+
+	for _, header := range headers {
+		fw, err := w.CreateHeader(header)
+		assert.NoError(t, err)
+
+		if header.Mode().IsRegular() {
+			_, err = fw.Write([]byte(header.Name))
+			assert.NoError(t, err)
+		}
+	}
+	assert.NoError(t, w.Close())
+
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+
+	return r
+}
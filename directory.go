@@ -0,0 +1,303 @@
+package pzip
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Records of the central directory, as defined in the zip specification (See 4.3.12 to 4.3.16
+// https://pkware.cachefly.net/webdocs/casestudies/APPNOTE.TXT).
+const (
+	directoryHeaderSignature = 0x02014b50
+	directoryEndSignature    = 0x06054b50
+	directory64LocSignature  = 0x07064b50
+	directory64EndSignature  = 0x06064b50
+	fileHeaderLen            = 30
+	directoryHeaderLen       = 46
+	directoryEndLen          = 22
+	directory64LocLen        = 20
+	directory64EndLen        = 56
+	zip64ExtraID             = 0x0001
+	zip64ExtraMaxLen         = 4 + 3*8 + 4
+	zipVersion45             = 45
+	uint16max                = 1<<16 - 1
+	uint32max                = 1<<32 - 1
+)
+
+var errDirectoryFormat = errors.New("malformed central directory")
+
+// A directoryHeader is a file header of the central directory. Only the fields which locate the local file header,
+// and those needed to read them, are decoded; the remaining fields are kept as is.
+type directoryHeader struct {
+	fixed            [directoryHeaderLen]byte
+	name             []byte
+	extra            []byte // without the Zip64 extra field
+	comment          []byte
+	compressedSize   uint64
+	uncompressedSize uint64
+	disk             uint32 // number of the disk on which the local file header starts
+	offset           uint64 // offset of the local file header, relative to the start of its disk
+}
+
+// readDirectoryHeader reads a file header of the central directory from r. It returns io.EOF if the next
+// record isn't a file header.
+func readDirectoryHeader(r io.Reader) (*directoryHeader, error) {
+	h := &directoryHeader{}
+	if _, err := io.ReadFull(r, h.fixed[:4]); err != nil {
+		return nil, fmt.Errorf("read signature: %w", err)
+	}
+	if binary.LittleEndian.Uint32(h.fixed[:]) != directoryHeaderSignature {
+		return nil, io.EOF
+	}
+
+	if _, err := io.ReadFull(r, h.fixed[4:]); err != nil {
+		return nil, fmt.Errorf("read file header: %w", err)
+	}
+
+	h.compressedSize = uint64(binary.LittleEndian.Uint32(h.fixed[20:]))
+	h.uncompressedSize = uint64(binary.LittleEndian.Uint32(h.fixed[24:]))
+	nameLen := binary.LittleEndian.Uint16(h.fixed[28:])
+	extraLen := binary.LittleEndian.Uint16(h.fixed[30:])
+	commentLen := binary.LittleEndian.Uint16(h.fixed[32:])
+	h.disk = uint32(binary.LittleEndian.Uint16(h.fixed[34:]))
+	h.offset = uint64(binary.LittleEndian.Uint32(h.fixed[42:]))
+
+	variable := make([]byte, int(nameLen)+int(extraLen)+int(commentLen))
+	if _, err := io.ReadFull(r, variable); err != nil {
+		return nil, fmt.Errorf("read file header: %w", err)
+	}
+	h.name = variable[:nameLen]
+	h.comment = variable[int(nameLen)+int(extraLen):]
+
+	extra := variable[nameLen : int(nameLen)+int(extraLen)]
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra)
+		size := int(binary.LittleEndian.Uint16(extra[2:]))
+		if 4+size > len(extra) {
+			break
+		}
+
+		if id == zip64ExtraID {
+			if err := h.readZip64Extra(extra[4 : 4+size]); err != nil {
+				return nil, err
+			}
+		} else {
+			h.extra = append(h.extra, extra[:4+size]...)
+		}
+		extra = extra[4+size:]
+	}
+
+	return h, nil
+}
+
+// readZip64Extra reads the fields of the Zip64 extended information extra field, which are only
+// present if the corresponding field of the header is at its maximum value.
+func (h *directoryHeader) readZip64Extra(data []byte) error {
+	read64 := func(field *uint64) error {
+		if len(data) < 8 {
+			return errDirectoryFormat
+		}
+		*field = binary.LittleEndian.Uint64(data)
+		data = data[8:]
+		return nil
+	}
+
+	if h.uncompressedSize == uint32max {
+		if err := read64(&h.uncompressedSize); err != nil {
+			return err
+		}
+	}
+	if h.compressedSize == uint32max {
+		if err := read64(&h.compressedSize); err != nil {
+			return err
+		}
+	}
+	if h.offset == uint32max {
+		if err := read64(&h.offset); err != nil {
+			return err
+		}
+	}
+	if h.disk == uint16max {
+		if len(data) < 4 {
+			return errDirectoryFormat
+		}
+		h.disk = binary.LittleEndian.Uint32(data)
+	}
+
+	return nil
+}
+
+// encode returns the header as a record of the central directory. Fields which don't fit are stored
+// in a Zip64 extra field.
+func (h *directoryHeader) encode() []byte {
+	fixed := h.fixed
+
+	zip64 := binary.LittleEndian.AppendUint16(nil, zip64ExtraID)
+	zip64 = binary.LittleEndian.AppendUint16(zip64, 0) // size to be filled in
+	uncompressedSize := uint32(min(h.uncompressedSize, uint32max))
+	if h.uncompressedSize >= uint32max {
+		zip64 = binary.LittleEndian.AppendUint64(zip64, h.uncompressedSize)
+	}
+	compressedSize := uint32(min(h.compressedSize, uint32max))
+	if h.compressedSize >= uint32max {
+		zip64 = binary.LittleEndian.AppendUint64(zip64, h.compressedSize)
+	}
+	offset := uint32(min(h.offset, uint32max))
+	if h.offset >= uint32max {
+		zip64 = binary.LittleEndian.AppendUint64(zip64, h.offset)
+	}
+	disk := uint16(min(h.disk, uint16max))
+	if h.disk >= uint16max {
+		zip64 = binary.LittleEndian.AppendUint32(zip64, h.disk)
+	}
+	binary.LittleEndian.PutUint16(zip64[2:], uint16(len(zip64)-4))
+
+	extra := h.extra
+	if len(zip64) > 4 {
+		extra = append(zip64, h.extra...)
+		if binary.LittleEndian.Uint16(fixed[6:]) < zipVersion45 {
+			binary.LittleEndian.PutUint16(fixed[6:], zipVersion45)
+		}
+	}
+
+	binary.LittleEndian.PutUint32(fixed[20:], compressedSize)
+	binary.LittleEndian.PutUint32(fixed[24:], uncompressedSize)
+	binary.LittleEndian.PutUint16(fixed[30:], uint16(len(extra)))
+	binary.LittleEndian.PutUint16(fixed[34:], disk)
+	binary.LittleEndian.PutUint32(fixed[42:], offset)
+
+	record := make([]byte, 0, len(fixed)+len(h.name)+len(extra)+len(h.comment))
+	record = append(record, fixed[:]...)
+	record = append(record, h.name...)
+	record = append(record, extra...)
+	return append(record, h.comment...)
+}
+
+// A directoryEnd holds the fields of the end of central directory records.
+type directoryEnd struct {
+	disk        uint32 // number of the disk with the end of central directory records
+	dirDisk     uint32 // number of the disk on which the central directory starts
+	diskRecords uint64 // number of file headers on this disk
+	records     uint64
+	size        uint64
+	offset      uint64 // offset of the central directory, relative to the start of dirDisk
+	zip64       bool
+	zip64Disk   uint32 // number of the disk with the Zip64 end of central directory record
+	zip64Offset uint64
+	disks       uint32
+	comment     []byte
+}
+
+// encode returns the end of central directory record, preceded by the Zip64 end of central directory
+// record and locator if needed.
+func (d *directoryEnd) encode() []byte {
+	zip64 := d.zip64 || d.needsZip64()
+
+	var buf []byte
+	if zip64 {
+		buf = binary.LittleEndian.AppendUint32(buf, directory64EndSignature)
+		buf = binary.LittleEndian.AppendUint64(buf, directory64EndLen-12) // excludes the signature and this field
+		buf = binary.LittleEndian.AppendUint16(buf, zipVersion45)         // version made by
+		buf = binary.LittleEndian.AppendUint16(buf, zipVersion45)         // version needed to extract
+		buf = binary.LittleEndian.AppendUint32(buf, d.disk)
+		buf = binary.LittleEndian.AppendUint32(buf, d.dirDisk)
+		buf = binary.LittleEndian.AppendUint64(buf, d.diskRecords)
+		buf = binary.LittleEndian.AppendUint64(buf, d.records)
+		buf = binary.LittleEndian.AppendUint64(buf, d.size)
+		buf = binary.LittleEndian.AppendUint64(buf, d.offset)
+
+		buf = binary.LittleEndian.AppendUint32(buf, directory64LocSignature)
+		buf = binary.LittleEndian.AppendUint32(buf, d.zip64Disk)
+		buf = binary.LittleEndian.AppendUint64(buf, d.zip64Offset)
+		buf = binary.LittleEndian.AppendUint32(buf, d.disks)
+	}
+
+	buf = binary.LittleEndian.AppendUint32(buf, directoryEndSignature)
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(min(d.disk, uint16max)))
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(min(d.dirDisk, uint16max)))
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(min(d.diskRecords, uint16max)))
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(min(d.records, uint16max)))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(min(d.size, uint32max)))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(min(d.offset, uint32max)))
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(d.comment)))
+	return append(buf, d.comment...)
+}
+
+// needsZip64 reports whether any of the fields don't fit in the end of central directory record.
+func (d *directoryEnd) needsZip64() bool {
+	return d.disk >= uint16max || d.dirDisk >= uint16max || d.records >= uint16max ||
+		d.size >= uint32max || d.offset >= uint32max
+}
+
+// readDirectoryEnd reads the end of central directory record from the last disk r, of the given size.
+// When Zip64 is used, the location of the Zip64 end of central directory record is read from its locator,
+// but the record itself isn't read, as it may be on another disk.
+func readDirectoryEnd(r io.ReaderAt, size int64) (*directoryEnd, error) {
+	// the record is followed by a comment of up to 64KiB
+	search := min(size, directoryEndLen+uint16max)
+	buf := make([]byte, search)
+	if _, err := r.ReadAt(buf, size-search); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("read end of central directory: %w", err)
+	}
+
+	start := -1
+	for i := len(buf) - directoryEndLen; i >= 0; i-- {
+		if binary.LittleEndian.Uint32(buf[i:]) == directoryEndSignature &&
+			i+directoryEndLen+int(binary.LittleEndian.Uint16(buf[i+20:])) <= len(buf) {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return nil, errors.New("end of central directory not found")
+	}
+
+	record := buf[start:]
+	d := &directoryEnd{
+		disk:        uint32(binary.LittleEndian.Uint16(record[4:])),
+		dirDisk:     uint32(binary.LittleEndian.Uint16(record[6:])),
+		diskRecords: uint64(binary.LittleEndian.Uint16(record[8:])),
+		records:     uint64(binary.LittleEndian.Uint16(record[10:])),
+		size:        uint64(binary.LittleEndian.Uint32(record[12:])),
+		offset:      uint64(binary.LittleEndian.Uint32(record[16:])),
+		comment:     record[directoryEndLen : directoryEndLen+int(binary.LittleEndian.Uint16(record[20:]))],
+		disks:       1,
+	}
+
+	locatorOffset := size - search + int64(start) - directory64LocLen
+	if locatorOffset >= 0 {
+		locator := make([]byte, directory64LocLen)
+		if _, err := r.ReadAt(locator, locatorOffset); err != nil {
+			return nil, fmt.Errorf("read zip64 end of central directory locator: %w", err)
+		}
+		if binary.LittleEndian.Uint32(locator) == directory64LocSignature {
+			d.zip64 = true
+			d.zip64Disk = binary.LittleEndian.Uint32(locator[4:])
+			d.zip64Offset = binary.LittleEndian.Uint64(locator[8:])
+			d.disks = binary.LittleEndian.Uint32(locator[16:])
+		}
+	}
+
+	return d, nil
+}
+
+// readZip64 reads the fields of the Zip64 end of central directory record from r.
+func (d *directoryEnd) readZip64(r io.Reader) error {
+	record := make([]byte, directory64EndLen)
+	if _, err := io.ReadFull(r, record); err != nil {
+		return fmt.Errorf("read zip64 end of central directory: %w", err)
+	}
+	if binary.LittleEndian.Uint32(record) != directory64EndSignature {
+		return errDirectoryFormat
+	}
+
+	d.disk = binary.LittleEndian.Uint32(record[16:])
+	d.dirDisk = binary.LittleEndian.Uint32(record[20:])
+	d.diskRecords = binary.LittleEndian.Uint64(record[24:])
+	d.records = binary.LittleEndian.Uint64(record[32:])
+	d.size = binary.LittleEndian.Uint64(record[40:])
+	d.offset = binary.LittleEndian.Uint64(record[48:])
+	return nil
+}
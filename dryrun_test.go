@@ -0,0 +1,58 @@
+package pzip
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/ybirader/pzip/internal/testutils"
+)
+
+func TestArchiverDryRun(t *testing.T) {
+	t.Run("lists the entries that would be archived, without writing the archive", func(t *testing.T) {
+		var archive bytes.Buffer
+		sizes := make(map[string]int64)
+		archiver, err := NewArchiver(&archive, ArchiverExclude("**/nested"), ArchiverDryRun(func(name string, info fs.FileInfo) {
+			sizes[name] = info.Size()
+		}))
+		assert.NoError(t, err)
+		assert.NoError(t, archiver.Archive(context.Background(), []string{helloTxtFileFixture, helloDirectoryFixture}))
+		assert.NoError(t, archiver.Close())
+
+		assert.Zero(t, archive.Len())
+		assert.Equal(t, 3, len(sizes))
+		assert.Equal(t, int64(14), sizes["hello.txt"])
+		assert.Equal(t, int64(54), sizes["hello/hello.txt"])
+		_, ok := sizes["hello/"]
+		assert.True(t, ok)
+	})
+
+	t.Run("lists the entries under the names they would be archived with", func(t *testing.T) {
+		var listed []string
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+		archiver, err := NewArchiver(archive, ArchiverDryRun(func(name string, info fs.FileInfo) {
+			listed = append(listed, name)
+		}))
+		assert.NoError(t, err)
+		assert.NoError(t, archiver.Archive(context.Background(), []string{helloDirectoryFixture}))
+		assert.NoError(t, archiver.Close())
+
+		path := filepath.Join(t.TempDir(), "archive.zip")
+		createArchive(t, path, []string{helloDirectoryFixture})
+		archiveReader := testutils.GetArchiveReader(t, path)
+		defer archiveReader.Close()
+
+		var archived []string
+		for _, file := range archiveReader.File {
+			archived = append(archived, file.Name)
+		}
+		sort.Strings(archived)
+		sort.Strings(listed)
+		assert.Equal(t, archived, listed)
+	})
+}
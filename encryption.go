@@ -0,0 +1,233 @@
+package pzip
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ybirader/pzip/pool"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// AES encryption of entries follows the WinZip AE-x specification (See https://www.winzip.com/en/support/aes-encryption/).
+// The data of an encrypted entry consists of a salt, a password verifier, the compressed contents encrypted using AES
+// in counter mode and an authentication code of the encrypted contents.
+const (
+	aesMethod         = 99
+	aesExtraID        = 0x9901
+	aesExtraSize      = 7
+	aesVendorID       = "AE"
+	aesVersion1       = 1 // the CRC-32 of the contents is stored
+	aesVersion2       = 2 // the CRC-32 isn't stored, as it could reveal information about the contents
+	aesKeyIterations  = 1000
+	aesVerifierSize   = 2
+	aesMACSize        = 10
+	aesReaderVersion  = 51
+	encryptedFlag     = 0x1
+	defaultAESKeySize = 256
+)
+
+var (
+	// ErrPassword is returned when extracting encrypted entries with a missing or incorrect password.
+	ErrPassword = errors.New("incorrect password")
+	// ErrAuthentication is returned when the contents of an AES encrypted entry fail authentication.
+	ErrAuthentication = errors.New("authentication failed")
+)
+
+// aesStrength returns the strength of AES encryption, as stored in the AES extra field, for keys of keySize bits.
+func aesStrength(keySize int) (byte, error) {
+	switch keySize {
+	case 128, 192, 256:
+		return byte(keySize/64 - 1), nil
+	default:
+		return 0, fmt.Errorf("unsupported AES key size %d", keySize)
+	}
+}
+
+// aesKeyLength returns the length in bytes of keys used for encryption of the given strength.
+func aesKeyLength(strength byte) int {
+	return 8 * (int(strength) + 1)
+}
+
+// aesSaltLength returns the length in bytes of the salt used for encryption of the given strength.
+func aesSaltLength(strength byte) int {
+	return aesKeyLength(strength) / 2
+}
+
+// aesKeys derives the encryption key, authentication key and password verifier from password and salt.
+func aesKeys(password, salt []byte, strength byte) (key, macKey, verifier []byte) {
+	keyLength := aesKeyLength(strength)
+	derived := pbkdf2.Key(password, salt, aesKeyIterations, 2*keyLength+aesVerifierSize, sha1.New)
+	return derived[:keyLength], derived[keyLength : 2*keyLength], derived[2*keyLength:]
+}
+
+// An encrypter encrypts the compressed contents of files with a password. Space for the header which precedes
+// the encrypted contents of a file is reserved before the file is compressed.
+type encrypter interface {
+	// headerSize returns the size of the header which precedes the encrypted contents of a file.
+	headerSize() int
+	// encrypt encrypts the compressed contents of file in place, filling in the reserved header, and marks
+	// the header of file as encrypted. The header of file must be populated.
+	encrypt(file *pool.File) error
+	// encrypts reports whether an entry with flags and method is encrypted in the same way, so that it can be
+	// copied as is.
+	encrypts(flags, method uint16) bool
+}
+
+// reserveHeader writes space for the header of size bytes to file, before its contents are compressed.
+func reserveHeader(file *pool.File, size int) error {
+	if _, err := file.Write(make([]byte, size)); err != nil {
+		return fmt.Errorf("reserve encryption header: %w", err)
+	}
+
+	return nil
+}
+
+// reservedHeader returns the reserved header of file, which is always held in memory.
+func reservedHeader(file *pool.File, size int) ([]byte, error) {
+	contents := file.CompressedData.Bytes()
+	if len(contents) < size {
+		return nil, errors.New("encryption header not reserved")
+	}
+
+	return contents[:size], nil
+}
+
+// encryptContents encrypts the compressed contents of file which follow its header of headerSize bytes
+// in place, using stream. The encrypted contents are also written to w.
+func encryptContents(file *pool.File, headerSize int, stream cipher.Stream, w io.Writer) error {
+	contents := file.CompressedData.Bytes()[headerSize:]
+	stream.XORKeyStream(contents, contents)
+	w.Write(contents)
+
+	if !file.Overflowed() {
+		return nil
+	}
+
+	buf := make([]byte, bufferSize)
+	for offset := int64(0); ; {
+		n, err := file.Overflow.ReadAt(buf, offset)
+		if n > 0 {
+			stream.XORKeyStream(buf[:n], buf[:n])
+			w.Write(buf[:n])
+			if _, werr := file.Overflow.WriteAt(buf[:n], offset); werr != nil {
+				return fmt.Errorf("write overflow: %w", werr)
+			}
+			offset += int64(n)
+		}
+
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("read overflow: %w", err)
+		}
+	}
+}
+
+// An aesEncrypter encrypts files using WinZip AES encryption.
+type aesEncrypter struct {
+	password []byte
+	strength byte
+}
+
+// headerSize returns the size of the salt and password verifier which precede the encrypted contents of a file.
+func (e *aesEncrypter) headerSize() int {
+	return aesSaltLength(e.strength) + aesVerifierSize
+}
+
+func (e *aesEncrypter) encrypts(flags, method uint16) bool {
+	return flags&encryptedFlag != 0 && method == aesMethod
+}
+
+// encrypt encrypts the compressed contents of file in place, filling in the reserved salt and password verifier
+// and appending the authentication code.
+func (e *aesEncrypter) encrypt(file *pool.File) error {
+	header, err := reservedHeader(file, e.headerSize())
+	if err != nil {
+		return err
+	}
+
+	salt := header[:aesSaltLength(e.strength)]
+	if _, err = rand.Read(salt); err != nil {
+		return fmt.Errorf("generate salt: %w", err)
+	}
+
+	key, macKey, verifier := aesKeys(e.password, salt, e.strength)
+	copy(header[len(salt):], verifier)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return fmt.Errorf("new cipher: %w", err)
+	}
+
+	mac := hmac.New(sha1.New, macKey)
+	if err = encryptContents(file, e.headerSize(), newAESCounter(block), mac); err != nil {
+		return fmt.Errorf("encrypt contents: %w", err)
+	}
+
+	if _, err = file.Write(mac.Sum(nil)[:aesMACSize]); err != nil {
+		return fmt.Errorf("write authentication code: %w", err)
+	}
+
+	// the compression method is stored in the extra field instead
+	method := file.Header.Method
+	file.Header.Method = aesMethod
+	file.Header.Flags |= encryptedFlag
+	file.Header.CRC32 = 0
+	file.Header.ReaderVersion = aesReaderVersion
+	file.Header.Extra = append(file.Header.Extra, aesExtraField(aesVersion2, e.strength, method)...)
+
+	return nil
+}
+
+// aesExtraField returns the AES extra field of an entry encrypted with the given version and strength,
+// with contents compressed using method.
+func aesExtraField(version uint16, strength byte, method uint16) []byte {
+	field := make([]byte, 0, 4+aesExtraSize)
+	field = binary.LittleEndian.AppendUint16(field, aesExtraID)
+	field = binary.LittleEndian.AppendUint16(field, aesExtraSize)
+	field = binary.LittleEndian.AppendUint16(field, version)
+	field = append(field, aesVendorID...)
+	field = append(field, strength)
+	field = binary.LittleEndian.AppendUint16(field, method)
+	return field
+}
+
+// aesCounter is AES in counter mode as used by WinZip, with a little-endian counter starting at 1.
+// This differs from cipher.NewCTR, which increments a big-endian counter.
+type aesCounter struct {
+	block     cipher.Block
+	counter   [aes.BlockSize]byte
+	keyStream [aes.BlockSize]byte
+	used      int
+}
+
+func newAESCounter(block cipher.Block) *aesCounter {
+	return &aesCounter{block: block, used: aes.BlockSize}
+}
+
+func (c *aesCounter) XORKeyStream(dst, src []byte) {
+	for len(src) > 0 {
+		if c.used == aes.BlockSize {
+			for i := range c.counter {
+				c.counter[i]++
+				if c.counter[i] != 0 {
+					break
+				}
+			}
+			c.block.Encrypt(c.keyStream[:], c.counter[:])
+			c.used = 0
+		}
+
+		n := subtle.XORBytes(dst, src, c.keyStream[c.used:])
+		c.used += n
+		dst, src = dst[n:], src[n:]
+	}
+}
@@ -0,0 +1,214 @@
+package pzip
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/aes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/ybirader/pzip/internal/testutils"
+)
+
+const testPassword = "correct horse battery staple"
+
+func TestAESCounter(t *testing.T) {
+	t.Run("encrypts a little-endian counter starting at one", func(t *testing.T) {
+		block, err := aes.NewCipher(make([]byte, 16))
+		assert.NoError(t, err)
+
+		keyStream := make([]byte, 257*aes.BlockSize)
+		newAESCounter(block).XORKeyStream(keyStream, keyStream)
+
+		for _, n := range []int{1, 2, 255, 256, 257} {
+			counter := make([]byte, aes.BlockSize)
+			counter[0], counter[1] = byte(n), byte(n>>8)
+			want := make([]byte, aes.BlockSize)
+			block.Encrypt(want, counter)
+
+			assert.Equal(t, want, keyStream[(n-1)*aes.BlockSize:n*aes.BlockSize])
+		}
+	})
+
+	t.Run("continues the key stream across calls", func(t *testing.T) {
+		block, err := aes.NewCipher(make([]byte, 16))
+		assert.NoError(t, err)
+
+		want := make([]byte, 100)
+		newAESCounter(block).XORKeyStream(want, want)
+
+		got := make([]byte, 100)
+		stream := newAESCounter(block)
+		stream.XORKeyStream(got[:7], got[:7])
+		stream.XORKeyStream(got[7:40], got[7:40])
+		stream.XORKeyStream(got[40:], got[40:])
+
+		assert.Equal(t, want, got)
+	})
+}
+
+func TestArchiverEncryption(t *testing.T) {
+	for _, method := range []uint16{zip.Store, zip.Deflate, Zstd} {
+		t.Run(fmt.Sprintf("encrypts and decrypts files compressed using method %d", method), func(t *testing.T) {
+			archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+			defer cleanup()
+
+			archiver, err := NewArchiver(archive, ArchiverMethod(method), ArchiverEncryption(testPassword, 256))
+			assert.NoError(t, err)
+			err = archiver.Archive(context.Background(), []string{helloDirectoryFixture})
+			assert.NoError(t, err)
+			assert.NoError(t, archiver.Close())
+
+			archiveReader := testutils.GetArchiveReader(t, archive.Name())
+			defer archiveReader.Close()
+
+			hello, found := testutils.Find(archiveReader.File, func(file *zip.File) bool {
+				return file.Name == "hello/hello.txt"
+			})
+			assert.True(t, found)
+			assert.Equal(t, uint16(aesMethod), hello.Method)
+			assert.NotZero(t, hello.Flags&encryptedFlag)
+			assert.Zero(t, hello.CRC32)
+
+			outputDir := extractArchive(t, archive.Name(), ExtractorPassword(testPassword))
+
+			want, err := os.ReadFile(filepath.Join(helloDirectoryFixture, "hello.txt"))
+			assert.NoError(t, err)
+			got, err := os.ReadFile(filepath.Join(outputDir, "hello", "hello.txt"))
+			assert.NoError(t, err)
+			assert.Equal(t, want, got)
+		})
+	}
+
+	t.Run("encrypts large files compressed in blocks and overflowing to disk", func(t *testing.T) {
+		// random contents don't compress, so they overflow the in-memory buffer
+		contents := make([]byte, 6*1024*1024)
+		_, err := rand.Read(contents)
+		assert.NoError(t, err)
+		filePath := filepath.Join(t.TempDir(), "large.bin")
+		assert.NoError(t, os.WriteFile(filePath, contents, 0644))
+
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		archiver, err := NewArchiver(archive, ArchiverConcurrency(4), ArchiverEncryption(testPassword, 128))
+		assert.NoError(t, err)
+		archiver.parallelThreshold = 2 * defaultBlockSize
+		err = archiver.Archive(context.Background(), []string{filePath})
+		assert.NoError(t, err)
+		assert.NoError(t, archiver.Close())
+
+		outputDir := extractArchive(t, archive.Name(), ExtractorPassword(testPassword))
+
+		got, err := os.ReadFile(filepath.Join(outputDir, "large.bin"))
+		assert.NoError(t, err)
+		assert.Equal(t, contents, got)
+	})
+
+	t.Run("returns an error for an empty password or unsupported key size", func(t *testing.T) {
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		_, err := NewArchiver(archive, ArchiverEncryption("", 256))
+		assert.Error(t, err)
+
+		_, err = NewArchiver(archive, ArchiverEncryption(testPassword, 512))
+		assert.Error(t, err)
+	})
+}
+
+func TestExtractEncrypted(t *testing.T) {
+	t.Run("returns an error for an incorrect password", func(t *testing.T) {
+		archivePath := createEncryptedArchive(t, zip.Store)
+
+		err := extractArchiveErr(t, archivePath, ExtractorPassword("incorrect"))
+		assert.True(t, errors.Is(err, ErrPassword), err.Error())
+	})
+
+	t.Run("returns an error when no password is given", func(t *testing.T) {
+		archivePath := createEncryptedArchive(t, zip.Store)
+
+		err := extractArchiveErr(t, archivePath)
+		assert.Error(t, err)
+	})
+
+	for _, method := range []uint16{zip.Store, zip.Deflate, Zstd} {
+		t.Run(fmt.Sprintf("returns an error for tampered contents compressed with method %d", method), func(t *testing.T) {
+			archivePath := createEncryptedArchive(t, method)
+			offset, _ := encryptedData(t, archivePath)
+			tamper(t, archivePath, offset+int64(aesSaltLength(3)+aesVerifierSize))
+
+			err := extractArchiveErr(t, archivePath, ExtractorPassword(testPassword))
+			assert.True(t, errors.Is(err, ErrAuthentication), err.Error())
+		})
+
+		t.Run(fmt.Sprintf("returns an error for a tampered authentication code with method %d", method), func(t *testing.T) {
+			archivePath := createEncryptedArchive(t, method)
+			offset, size := encryptedData(t, archivePath)
+			tamper(t, archivePath, offset+size-1)
+
+			err := extractArchiveErr(t, archivePath, ExtractorPassword(testPassword))
+			assert.True(t, errors.Is(err, ErrAuthentication), err.Error())
+		})
+	}
+}
+
+// encryptedData returns the offset and size of the encrypted contents of the first entry of the archive at path.
+func encryptedData(t testing.TB, path string) (offset, size int64) {
+	t.Helper()
+
+	archiveReader := testutils.GetArchiveReader(t, path)
+	defer archiveReader.Close()
+
+	offset, err := archiveReader.File[0].DataOffset()
+	assert.NoError(t, err)
+	return offset, int64(archiveReader.File[0].CompressedSize64)
+}
+
+// tamper flips the bits of the byte at offset in the file at path.
+func tamper(t testing.TB, path string, offset int64) {
+	t.Helper()
+
+	contents, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	contents[offset] ^= 0xff
+	assert.NoError(t, os.WriteFile(path, contents, 0644))
+}
+
+// createEncryptedArchive returns the path to an archive of the hello.txt fixture, compressed using method and
+// encrypted with testPassword.
+func createEncryptedArchive(t testing.TB, method uint16) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "encrypted.zip")
+	createArchive(t, path, []string{helloTxtFileFixture}, ArchiverMethod(method), ArchiverEncryption(testPassword, 256))
+	return path
+}
+
+// extractArchive extracts the archive at path to a temporary directory, which is returned.
+func extractArchive(t testing.TB, path string, options ...extractorOption) string {
+	t.Helper()
+
+	outputDir := t.TempDir()
+	extractor, err := NewExtractor(outputDir, options...)
+	assert.NoError(t, err)
+	defer extractor.Close()
+
+	assert.NoError(t, extractor.Extract(context.Background(), path))
+	return outputDir
+}
+
+func extractArchiveErr(t testing.TB, path string, options ...extractorOption) error {
+	t.Helper()
+
+	extractor, err := NewExtractor(t.TempDir(), options...)
+	assert.NoError(t, err)
+	defer extractor.Close()
+
+	return extractor.Extract(context.Background(), path)
+}
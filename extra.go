@@ -2,28 +2,313 @@ package pzip
 
 import (
 	"encoding/binary"
+	"math"
 	"time"
 )
 
 const extendedTimestampTag = 0x5455
 
 // ExtendedTimeStampExtraField is the extended timestamp field, as defined in the zip specification (See 4.5.3 https://pkware.cachefly.net/webdocs/casestudies/APPNOTE.TXT).
+// Times are stored as 32-bit Unix times, with a precision of a second.
 type ExtendedTimestampExtraField struct {
 	modified time.Time
+	accessed time.Time
+	created  time.Time
 }
 
 func NewExtendedTimestampExtraField(modified time.Time) *ExtendedTimestampExtraField {
 	return &ExtendedTimestampExtraField{
-		modified,
+		modified: modified,
 	}
 }
 
-// Encode returns the modified time of the associated ExtendedTimestampExtraField as a slice of bytes.
+// WithAccessTime sets the access time of the associated ExtendedTimestampExtraField. A zero time isn't stored.
+func (e *ExtendedTimestampExtraField) WithAccessTime(accessed time.Time) *ExtendedTimestampExtraField {
+	e.accessed = accessed
+	return e
+}
+
+// WithCreationTime sets the creation time of the associated ExtendedTimestampExtraField. A zero time isn't stored.
+func (e *ExtendedTimestampExtraField) WithCreationTime(created time.Time) *ExtendedTimestampExtraField {
+	e.created = created
+	return e
+}
+
+// Modified returns the modification time of the associated ExtendedTimestampExtraField, or a zero time if it has none.
+func (e *ExtendedTimestampExtraField) Modified() time.Time {
+	return e.modified
+}
+
+// Accessed returns the access time of the associated ExtendedTimestampExtraField, or a zero time if it has none.
+func (e *ExtendedTimestampExtraField) Accessed() time.Time {
+	return e.accessed
+}
+
+// Created returns the creation time of the associated ExtendedTimestampExtraField, or a zero time if it has none.
+func (e *ExtendedTimestampExtraField) Created() time.Time {
+	return e.created
+}
+
+// Encode returns the times of the associated ExtendedTimestampExtraField as a slice of bytes.
 func (e *ExtendedTimestampExtraField) Encode() []byte {
-	extraBuf := make([]byte, 0, 9) // 2*SizeOf(uint16) + SizeOf(uint) + SizeOf(uint32)
+	var times []byte
+	for _, t := range []time.Time{e.modified, e.accessed, e.created} {
+		if !t.IsZero() {
+			times = binary.LittleEndian.AppendUint32(times, uint32(t.Unix()))
+		}
+	}
+
+	extraBuf := make([]byte, 0, 5+len(times)) // 2*SizeOf(uint16) + SizeOf(uint8) + n*SizeOf(uint32)
+	extraBuf = binary.LittleEndian.AppendUint16(extraBuf, extendedTimestampTag)
+	extraBuf = binary.LittleEndian.AppendUint16(extraBuf, uint16(1+len(times))) // block size
+	extraBuf = append(extraBuf, e.flags())
+	return append(extraBuf, times...)
+}
+
+// EncodeCentral returns the associated ExtendedTimestampExtraField as a slice of bytes in the form used by central
+// directory headers, which only holds the modification time, following the flags of the local header's field.
+func (e *ExtendedTimestampExtraField) EncodeCentral() []byte {
+	var times []byte
+	if !e.modified.IsZero() {
+		times = binary.LittleEndian.AppendUint32(times, uint32(e.modified.Unix()))
+	}
+
+	extraBuf := make([]byte, 0, 5+len(times)) // 2*SizeOf(uint16) + SizeOf(uint8) + SizeOf(uint32)
 	extraBuf = binary.LittleEndian.AppendUint16(extraBuf, extendedTimestampTag)
-	extraBuf = binary.LittleEndian.AppendUint16(extraBuf, 5) // block size
-	extraBuf = append(extraBuf, uint8(1))                    // flags
-	extraBuf = binary.LittleEndian.AppendUint32(extraBuf, uint32(e.modified.Unix()))
+	extraBuf = binary.LittleEndian.AppendUint16(extraBuf, uint16(1+len(times))) // block size
+	extraBuf = append(extraBuf, e.flags())
+	return append(extraBuf, times...)
+}
+
+// flags returns the flags of the times which are set, as stored in the local header's field.
+func (e *ExtendedTimestampExtraField) flags() uint8 {
+	var flags uint8
+	for i, t := range []time.Time{e.modified, e.accessed, e.created} {
+		if !t.IsZero() {
+			flags |= 1 << i
+		}
+	}
+	return flags
+}
+
+// centralExtra returns the extra fields of a local file header in the form used by central directory headers,
+// replacing the extended timestamp field with one which only holds the modification time.
+func centralExtra(extra []byte) []byte {
+	central := make([]byte, 0, len(extra))
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra)
+		size := int(binary.LittleEndian.Uint16(extra[2:]))
+		if 4+size > len(extra) {
+			break
+		}
+
+		field := extra[:4+size]
+		if id == extendedTimestampTag {
+			if timestamp, ok := ParseExtendedTimestampExtraField(field); ok {
+				field = timestamp.EncodeCentral()
+			}
+		}
+		central = append(central, field...)
+		extra = extra[4+size:]
+	}
+
+	return append(central, extra...)
+}
+
+// ParseExtendedTimestampExtraField returns the ExtendedTimestampExtraField found in extra, the extra fields
+// of a zip entry. Times which are flagged as present but missing, as in central directory headers written
+// by Info-ZIP, are left zero. It returns false if there is no such field.
+func ParseExtendedTimestampExtraField(extra []byte) (*ExtendedTimestampExtraField, bool) {
+	data, ok := findExtraField(extra, extendedTimestampTag)
+	if !ok || len(data) < 1 {
+		return nil, false
+	}
+
+	flags, data := data[0], data[1:]
+	var times [3]time.Time
+	for i := range times {
+		if flags&(1<<i) == 0 || len(data) < 4 {
+			continue
+		}
+		times[i] = time.Unix(int64(int32(binary.LittleEndian.Uint32(data))), 0)
+		data = data[4:]
+	}
+
+	return &ExtendedTimestampExtraField{modified: times[0], accessed: times[1], created: times[2]}, true
+}
+
+const (
+	ntfsExtraTag     = 0x000a
+	ntfsTimesTag     = 0x0001
+	ntfsTicksPerSec  = 10_000_000
+	ntfsEpochSeconds = -11644473600 // the Windows epoch, 1601-01-01, in seconds since the Unix epoch
+)
+
+// NTFSExtraField is the NTFS extra field, as defined in the zip specification (See 4.5.5 https://pkware.cachefly.net/webdocs/casestudies/APPNOTE.TXT).
+// Times are stored as 64-bit Windows file times, with a precision of 100ns. Zero times aren't set.
+type NTFSExtraField struct {
+	Modified time.Time
+	Accessed time.Time
+	Created  time.Time
+}
+
+func NewNTFSExtraField(modified, accessed, created time.Time) *NTFSExtraField {
+	return &NTFSExtraField{Modified: modified, Accessed: accessed, Created: created}
+}
+
+// Encode returns the times of the associated NTFSExtraField as a slice of bytes.
+func (n *NTFSExtraField) Encode() []byte {
+	extraBuf := make([]byte, 0, 36) // 4*SizeOf(uint16) + SizeOf(uint32) + 3*SizeOf(uint64)
+	extraBuf = binary.LittleEndian.AppendUint16(extraBuf, ntfsExtraTag)
+	extraBuf = binary.LittleEndian.AppendUint16(extraBuf, 32) // block size
+	extraBuf = binary.LittleEndian.AppendUint32(extraBuf, 0)  // reserved
+	extraBuf = binary.LittleEndian.AppendUint16(extraBuf, ntfsTimesTag)
+	extraBuf = binary.LittleEndian.AppendUint16(extraBuf, 24) // attribute size
+	for _, t := range []time.Time{n.Modified, n.Accessed, n.Created} {
+		extraBuf = binary.LittleEndian.AppendUint64(extraBuf, toFileTime(t))
+	}
 	return extraBuf
 }
+
+// ParseNTFSExtraField returns the NTFSExtraField found in extra, the extra fields of a zip entry.
+// It returns false if there is no such field, or it doesn't hold times.
+func ParseNTFSExtraField(extra []byte) (*NTFSExtraField, bool) {
+	data, ok := findExtraField(extra, ntfsExtraTag)
+	if !ok || len(data) < 4 {
+		return nil, false
+	}
+
+	// the reserved field is followed by attributes, of which only the times are defined
+	for data = data[4:]; len(data) >= 4; {
+		tag := binary.LittleEndian.Uint16(data)
+		size := int(binary.LittleEndian.Uint16(data[2:]))
+		data = data[4:]
+		if size > len(data) {
+			break
+		}
+
+		if tag == ntfsTimesTag && size >= 24 {
+			return NewNTFSExtraField(
+				fromFileTime(binary.LittleEndian.Uint64(data)),
+				fromFileTime(binary.LittleEndian.Uint64(data[8:])),
+				fromFileTime(binary.LittleEndian.Uint64(data[16:])),
+			), true
+		}
+		data = data[size:]
+	}
+
+	return nil, false
+}
+
+// toFileTime returns t as the number of 100ns intervals since the Windows epoch. A zero time is returned as zero.
+// Times which can't be represented are clamped, so that times before the Windows epoch are returned as the
+// earliest time after it, as zero isn't a time.
+func toFileTime(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	seconds := t.Unix() - ntfsEpochSeconds
+	if seconds < 0 {
+		return 1
+	}
+	if uint64(seconds) >= math.MaxUint64/ntfsTicksPerSec {
+		return math.MaxUint64
+	}
+
+	return max(uint64(seconds)*ntfsTicksPerSec+uint64(t.Nanosecond()/100), 1)
+}
+
+// fromFileTime returns the time of ticks, a number of 100ns intervals since the Windows epoch. Zero is
+// returned as a zero time.
+func fromFileTime(ticks uint64) time.Time {
+	if ticks == 0 {
+		return time.Time{}
+	}
+
+	seconds := int64(ticks/ntfsTicksPerSec) + ntfsEpochSeconds
+	return time.Unix(seconds, int64(ticks%ntfsTicksPerSec)*100)
+}
+
+const unixExtraTag = 0x7875
+
+// UnixExtraField is the Info-ZIP new Unix extra field, holding the user and group IDs of the owner of a file
+// (See 4.6.17 https://libzip.org/specifications/extrafld.txt).
+type UnixExtraField struct {
+	UID uint32
+	GID uint32
+}
+
+func NewUnixExtraField(uid, gid uint32) *UnixExtraField {
+	return &UnixExtraField{UID: uid, GID: gid}
+}
+
+// Encode returns the user and group IDs of the associated UnixExtraField as a slice of bytes.
+func (u *UnixExtraField) Encode() []byte {
+	extraBuf := make([]byte, 0, 15) // 2*SizeOf(uint16) + 3*SizeOf(uint8) + 2*SizeOf(uint32)
+	extraBuf = binary.LittleEndian.AppendUint16(extraBuf, unixExtraTag)
+	extraBuf = binary.LittleEndian.AppendUint16(extraBuf, 11) // block size
+	extraBuf = append(extraBuf, uint8(1))                     // version
+	extraBuf = append(extraBuf, uint8(4))                     // size of UID
+	extraBuf = binary.LittleEndian.AppendUint32(extraBuf, u.UID)
+	extraBuf = append(extraBuf, uint8(4)) // size of GID
+	extraBuf = binary.LittleEndian.AppendUint32(extraBuf, u.GID)
+	return extraBuf
+}
+
+// ParseUnixExtraField returns the UnixExtraField found in extra, the extra fields of a zip entry.
+// It returns false if there is no such field, or its IDs don't fit in 32 bits.
+func ParseUnixExtraField(extra []byte) (*UnixExtraField, bool) {
+	data, ok := findExtraField(extra, unixExtraTag)
+	if !ok || len(data) < 2 || data[0] != 1 {
+		return nil, false
+	}
+
+	uid, data, ok := readVariableID(data[1:])
+	if !ok {
+		return nil, false
+	}
+	gid, _, ok := readVariableID(data)
+	if !ok {
+		return nil, false
+	}
+
+	return NewUnixExtraField(uid, gid), true
+}
+
+// readVariableID reads an ID of the Unix extra field, which is preceded by its size in bytes.
+func readVariableID(data []byte) (uint32, []byte, bool) {
+	if len(data) < 1 || len(data) < 1+int(data[0]) {
+		return 0, nil, false
+	}
+
+	size := int(data[0])
+	var id uint64
+	for i := size - 1; i >= 0; i-- {
+		id = id<<8 | uint64(data[1+i])
+	}
+	if id > math.MaxUint32 {
+		return 0, nil, false
+	}
+
+	return uint32(id), data[1+size:], true
+}
+
+// findExtraField returns the data of the extra field with the given tag in extra.
+func findExtraField(extra []byte, tag uint16) ([]byte, bool) {
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra)
+		size := int(binary.LittleEndian.Uint16(extra[2:]))
+		extra = extra[4:]
+		if size > len(extra) {
+			break
+		}
+
+		if id == tag {
+			return extra[:size], true
+		}
+		extra = extra[size:]
+	}
+
+	return nil, false
+}
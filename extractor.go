@@ -2,22 +2,31 @@ package pzip
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/klauspost/compress/zip"
+	"github.com/klauspost/compress/zstd"
 	"github.com/ybirader/pzip/pool"
 )
 
 type extractor struct {
 	outputDir      string
-	archiveReader  *zip.ReadCloser
+	realOutputDir  string // outputDir with symbolic links resolved
+	archiveReader  *zip.Reader
+	archiveCloser  io.Closer
 	fileWorkerPool pool.WorkerPool[zip.File]
 	concurrency    int
+	password       []byte
+	restoreOwners  bool
+	progress       *progressTracker
 }
 
 // NewExtractor returns a new pzip extractor. The extractor can be configured by passing in a number of options.
@@ -30,15 +39,7 @@ func NewExtractor(outputDir string, options ...extractorOption) (*extractor, err
 	}
 	e := &extractor{outputDir: absOutputDir, concurrency: runtime.GOMAXPROCS(0)}
 
-	fileExecutor := func(file *zip.File) error {
-		if err := e.extractFile(file); err != nil {
-			return fmt.Errorf("extract file %q: %w", file.Name, err)
-		}
-
-		return nil
-	}
-
-	fileWorkerPool, err := pool.NewFileWorkerPool(fileExecutor, &pool.Config{Concurrency: e.concurrency, Capacity: 10})
+	fileWorkerPool, err := pool.NewFileWorkerPool(e.processFile, &pool.Config{Concurrency: e.concurrency, Capacity: 10})
 	if err != nil {
 		return nil, fmt.Errorf("new file worker pool: %w", err)
 	}
@@ -57,15 +58,38 @@ func NewExtractor(outputDir string, options ...extractorOption) (*extractor, err
 // Extract extracts the files from the specified archivePath to
 // the corresponding outputDir registered with the extractor. Extraction is canceled when the
 // associated ctx is canceled. The first error that arises during extraction is returned.
+// When archivePath is the last volume of a split archive, such as archive.zip of archive.z01,
+// archive.z02 and archive.zip, the other volumes are read from the same directory.
+// Symbolic links are created once all other files are extracted, so no file is written through them.
 func (e *extractor) Extract(ctx context.Context, archivePath string) (err error) {
-	e.archiveReader, err = zip.OpenReader(archivePath)
+	e.realOutputDir, err = realPath(e.outputDir)
+	if err != nil {
+		return fmt.Errorf("resolve output directory %q: %w", e.outputDir, err)
+	}
+
+	e.archiveReader, e.archiveCloser, err = openArchive(archivePath)
 	if err != nil {
 		return fmt.Errorf("open archive %q: %w", archivePath, err)
 	}
+	e.archiveReader.RegisterDecompressor(zstd.ZipMethodWinZip, zstd.ZipDecompressor())
+	e.archiveReader.RegisterDecompressor(zstd.ZipMethodPKWare, zstd.ZipDecompressor())
+
+	e.progress.update(func(progress *Progress) {
+		for _, file := range e.archiveReader.File {
+			progress.TotalFiles++
+			progress.TotalBytes += int64(file.UncompressedSize64)
+		}
+		progress.Counted = true
+	})
 
 	e.fileWorkerPool.Start(ctx)
 
+	var symlinks []*zip.File
 	for _, file := range e.archiveReader.File {
+		if file.Mode()&fs.ModeSymlink != 0 {
+			symlinks = append(symlinks, file)
+			continue
+		}
 		e.fileWorkerPool.Enqueue(file)
 	}
 
@@ -73,39 +97,151 @@ func (e *extractor) Extract(ctx context.Context, archivePath string) (err error)
 		return fmt.Errorf("close file worker pool: %w", err)
 	}
 
+	// links are created in order, so each one is checked against the links created before it
+	for _, file := range symlinks {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if err = e.processFile(file); err != nil {
+			return err
+		}
+	}
+
+	// extracting files changes the modification times of the directories containing them, so the times
+	// of directories are restored once all files are extracted
+	for _, file := range e.archiveReader.File {
+		if !e.isDir(file.Name) {
+			continue
+		}
+
+		if err = e.restoreTimes(e.outputPath(file.Name), file); err != nil {
+			return fmt.Errorf("restore times of %q: %w", file.Name, err)
+		}
+	}
+
+	e.progress.finish()
 	return nil
 }
 
 func (e *extractor) Close() error {
-	if err := e.archiveReader.Close(); err != nil {
+	if err := e.archiveCloser.Close(); err != nil {
 		return fmt.Errorf("close archive reader: %w", err)
 	}
 
 	return nil
 }
 
+func (e *extractor) processFile(file *zip.File) error {
+	if err := e.extractFile(file); err != nil {
+		return fmt.Errorf("extract file %q: %w", file.Name, err)
+	}
+
+	e.progress.update(func(progress *Progress) {
+		progress.FilesCompressed++
+		progress.FilesWritten++
+		progress.BytesCompressed += int64(file.CompressedSize64)
+	})
+
+	return nil
+}
+
 func (e *extractor) extractFile(file *zip.File) (err error) {
 	outputPath := e.outputPath(file.Name)
 
+	// the directory is resolved on disk, as links extracted before may lead outside of the output directory
 	dir := filepath.Dir(outputPath)
+	realDir, err := realPath(dir)
+	if err != nil {
+		return fmt.Errorf("resolve directory %q: %w", dir, err)
+	}
+	if !e.isWithinOutputDir(realDir) {
+		return fmt.Errorf("directory %q is outside of output directory", dir)
+	}
+
 	if err = os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("create directory %q: %w", dir, err)
 	}
 
-	if e.isDir(file.Name) {
+	switch {
+	case e.isDir(file.Name):
 		if err = e.writeDir(outputPath, file); err != nil {
 			return fmt.Errorf("write directory %q: %w", file.Name, err)
 		}
+	case file.Mode()&fs.ModeSymlink != 0:
+		if err = e.writeSymlink(outputPath, file); err != nil {
+			return fmt.Errorf("write symlink %q: %w", file.Name, err)
+		}
+	default:
+		if err = e.writeFile(outputPath, file); err != nil {
+			return fmt.Errorf("write file %q: %w", file.Name, err)
+		}
+
+		if err = e.restoreTimes(outputPath, file); err != nil {
+			return fmt.Errorf("restore times of %q: %w", file.Name, err)
+		}
+	}
+
+	if err = e.restoreOwner(outputPath, file); err != nil {
+		return fmt.Errorf("restore owner of %q: %w", file.Name, err)
+	}
+
+	return nil
+}
+
+// restoreOwner changes the owner of the extracted file at outputPath, or of the link itself for symbolic links,
+// to the owner stored in the Unix extra field of file, if configured to and running as root.
+func (e *extractor) restoreOwner(outputPath string, file *zip.File) error {
+	if !e.restoreOwners || os.Geteuid() != 0 {
+		return nil
+	}
+
+	owner, ok := ParseUnixExtraField(file.Extra)
+	if !ok {
+		return nil
+	}
+
+	if err := os.Lchown(outputPath, int(owner.UID), int(owner.GID)); err != nil {
+		return fmt.Errorf("lchown %q: %w", outputPath, err)
+	}
+
+	return nil
+}
+
+// restoreTimes sets the modification and access times of the extracted file at outputPath to those stored
+// for file. Times of symbolic links aren't restored, as setting them would change the files they refer to.
+func (e *extractor) restoreTimes(outputPath string, file *zip.File) error {
+	modified, accessed := fileTimesOf(file)
+	if modified.IsZero() {
 		return nil
 	}
 
-	if err = e.writeFile(outputPath, file); err != nil {
-		return fmt.Errorf("write file %q: %w", file.Name, err)
+	if err := os.Chtimes(outputPath, accessed, modified); err != nil {
+		return fmt.Errorf("chtimes %q: %w", outputPath, err)
 	}
 
 	return nil
 }
 
+// fileTimesOf returns the modification and access times of file, preferring the most precise timestamp
+// stored for it: NTFS times, with a precision of 100ns, then extended timestamps, with a precision of a
+// second, and finally the MS-DOS time, with a precision of two seconds. When no access time is stored,
+// the modification time is returned for it.
+func fileTimesOf(file *zip.File) (modified, accessed time.Time) {
+	if ntfs, ok := ParseNTFSExtraField(file.Extra); ok && !ntfs.Modified.IsZero() {
+		modified, accessed = ntfs.Modified, ntfs.Accessed
+	} else if timestamp, ok := ParseExtendedTimestampExtraField(file.Extra); ok && !timestamp.Modified().IsZero() {
+		modified, accessed = timestamp.Modified(), timestamp.Accessed()
+	} else {
+		modified = file.Modified
+	}
+
+	if accessed.IsZero() {
+		accessed = modified
+	}
+
+	return modified, accessed
+}
+
 func (e *extractor) writeDir(outputPath string, file *zip.File) error {
 	err := os.Mkdir(outputPath, file.Mode())
 	if os.IsExist(err) {
@@ -120,6 +256,13 @@ func (e *extractor) writeDir(outputPath string, file *zip.File) error {
 }
 
 func (e *extractor) writeFile(outputPath string, file *zip.File) (err error) {
+	// an existing link is replaced rather than written through
+	if info, lerr := os.Lstat(outputPath); lerr == nil && info.Mode()&fs.ModeSymlink != 0 {
+		if err = os.Remove(outputPath); err != nil {
+			return fmt.Errorf("remove existing link %q: %w", outputPath, err)
+		}
+	}
+
 	outputFile, err := os.OpenFile(outputPath, os.O_CREATE|os.O_WRONLY, file.Mode())
 	if err != nil {
 		return fmt.Errorf("create file %q: %w", outputPath, err)
@@ -130,7 +273,7 @@ func (e *extractor) writeFile(outputPath string, file *zip.File) (err error) {
 		}
 	}()
 
-	srcFile, err := file.Open()
+	srcFile, err := e.open(file)
 	if err != nil {
 		return fmt.Errorf("open file %q: %w", file.Name, err)
 	}
@@ -140,13 +283,74 @@ func (e *extractor) writeFile(outputPath string, file *zip.File) (err error) {
 		}
 	}()
 
-	if _, err = io.Copy(outputFile, srcFile); err != nil {
+	if _, err = io.Copy(e.progress.writer(outputFile), e.progress.reader(srcFile)); err != nil {
 		return fmt.Errorf("decompress file %q: %w", file.Name, err)
 	}
 
 	return nil
 }
 
+// writeSymlink creates a symbolic link at outputPath to the target stored as the contents of file.
+// Links to targets outside of the output directory are rejected, following the links already on disk.
+func (e *extractor) writeSymlink(outputPath string, file *zip.File) error {
+	srcFile, err := e.open(file)
+	if err != nil {
+		return fmt.Errorf("open file %q: %w", file.Name, err)
+	}
+	defer srcFile.Close()
+
+	target, err := io.ReadAll(srcFile)
+	if err != nil {
+		return fmt.Errorf("read link target of %q: %w", file.Name, err)
+	}
+
+	// the target isn't cleaned before it's resolved, as ".." following a link leads to the parent of its target
+	resolvedTarget := string(target)
+	if !filepath.IsAbs(resolvedTarget) {
+		resolvedTarget = filepath.Dir(outputPath) + string(filepath.Separator) + resolvedTarget
+	}
+	resolvedTarget, err = realPath(resolvedTarget)
+	if err != nil {
+		return fmt.Errorf("resolve link target %q: %w", target, err)
+	}
+	if !e.isWithinOutputDir(resolvedTarget) {
+		return fmt.Errorf("link target %q is outside of output directory", target)
+	}
+
+	if err = os.Remove(outputPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove existing file %q: %w", outputPath, err)
+	}
+
+	if err = os.Symlink(string(target), outputPath); err != nil {
+		return fmt.Errorf("create symlink %q: %w", outputPath, err)
+	}
+
+	return nil
+}
+
+// open opens the contents of file, decrypting them with the password of the extractor if file is encrypted.
+func (e *extractor) open(file *zip.File) (io.ReadCloser, error) {
+	if file.Flags&encryptedFlag == 0 {
+		return file.Open()
+	}
+
+	if e.password == nil {
+		return nil, errors.New("file is encrypted, but no password was given")
+	}
+
+	if file.Method == aesMethod {
+		return openAES(file, e.password)
+	}
+
+	return openZipCrypto(file, e.password)
+}
+
+// isWithinOutputDir reports whether path, with symbolic links resolved, is within the output directory.
+func (e *extractor) isWithinOutputDir(path string) bool {
+	relativePath, err := filepath.Rel(e.realOutputDir, path)
+	return err == nil && relativePath != ".." && !strings.HasPrefix(relativePath, ".."+string(filepath.Separator))
+}
+
 func (e *extractor) isDir(name string) bool {
 	return strings.HasSuffix(filepath.ToSlash(name), "/")
 }
@@ -154,3 +358,28 @@ func (e *extractor) isDir(name string) bool {
 func (e *extractor) outputPath(name string) string {
 	return filepath.Join(e.outputDir, name)
 }
+
+// realPath returns path with the symbolic links in its longest existing prefix resolved. The elements of
+// path which don't exist yet are joined to the resolved prefix.
+func realPath(path string) (string, error) {
+	separator := string(filepath.Separator)
+	elems := strings.Split(path, separator)
+	for i := len(elems); i > 0; i-- {
+		prefix := strings.Join(elems[:i], separator)
+		if prefix == "" {
+			prefix = separator
+		}
+
+		resolved, err := filepath.EvalSymlinks(prefix)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("eval symlinks %q: %w", prefix, err)
+		}
+
+		return filepath.Join(append([]string{resolved}, elems[i:]...)...), nil
+	}
+
+	return filepath.Clean(path), nil
+}
@@ -1,6 +1,9 @@
 package pzip
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 type extractorOption func(*extractor) error
 
@@ -16,3 +19,35 @@ func ExtractorConcurrency(n int) extractorOption {
 		return nil
 	}
 }
+
+// ExtractorPassword sets the password used to decrypt encrypted files. An error is returned if password is empty.
+func ExtractorPassword(password string) extractorOption {
+	return func(e *extractor) error {
+		if password == "" {
+			return errors.New("empty password")
+		}
+
+		e.password = []byte(password)
+		return nil
+	}
+}
+
+// ExtractorOwnership restores the user and group owning extracted files, as stored in the Unix extra field
+// of their entries, as done by unzip -X. As changing the owner of files requires privileges, owners are only
+// restored when running as root; otherwise files are owned by the user running the extractor.
+func ExtractorOwnership() extractorOption {
+	return func(e *extractor) error {
+		e.restoreOwners = true
+		return nil
+	}
+}
+
+// ExtractorProgress reports the progress of extraction to report, as entries are read and files are written.
+// Progress is reported at most every 100ms, and once more when Extract returns. Calls to report are serialized,
+// but made from the goroutines extracting files, so report should return quickly.
+func ExtractorProgress(report func(progress Progress)) extractorOption {
+	return func(e *extractor) error {
+		e.progress = newProgressTracker(report)
+		return nil
+	}
+}
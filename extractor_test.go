@@ -1,6 +1,7 @@
 package pzip
 
 import (
+	"archive/zip"
 	"context"
 	"io/fs"
 	"os"
@@ -37,4 +38,152 @@ func TestExtract(t *testing.T) {
 		helloFileInfo := files[0]
 		assert.NotZero(t, helloFileInfo.Size())
 	})
+	t.Run("extracts files compressed with zstandard", func(t *testing.T) {
+		err := os.Mkdir(outputDirPath, 0755)
+		assert.NoError(t, err)
+		defer os.RemoveAll(outputDirPath)
+
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		archiver, err := NewArchiver(archive, ArchiverMethod(Zstd))
+		assert.NoError(t, err)
+		err = archiver.Archive(context.Background(), []string{helloDirectoryFixture})
+		assert.NoError(t, err)
+		archiver.Close()
+
+		extractor, err := NewExtractor(outputDirPath)
+		assert.NoError(t, err)
+		defer extractor.Close()
+
+		err = extractor.Extract(context.Background(), archive.Name())
+		assert.NoError(t, err)
+
+		want, err := os.ReadFile(filepath.Join(helloDirectoryFixture, "hello.txt"))
+		assert.NoError(t, err)
+		got, err := os.ReadFile(filepath.Join(outputDirPath, "hello", "hello.txt"))
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+	t.Run("recreates symbolic links", func(t *testing.T) {
+		err := os.Mkdir(outputDirPath, 0755)
+		assert.NoError(t, err)
+		defer os.RemoveAll(outputDirPath)
+
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		archiver, err := NewArchiver(archive, ArchiverStoreSymlinks())
+		assert.NoError(t, err)
+		err = archiver.Archive(context.Background(), []string{createSymlinkFixture(t)})
+		assert.NoError(t, err)
+		archiver.Close()
+
+		extractor, err := NewExtractor(outputDirPath)
+		assert.NoError(t, err)
+		defer extractor.Close()
+
+		err = extractor.Extract(context.Background(), archive.Name())
+		assert.NoError(t, err)
+
+		target, err := os.Readlink(filepath.Join(outputDirPath, "links", "link.txt"))
+		assert.NoError(t, err)
+		assert.Equal(t, "hello.txt", target)
+
+		contents, err := os.ReadFile(filepath.Join(outputDirPath, "links", "linked", "hello.txt"))
+		assert.NoError(t, err)
+		assert.Equal(t, "hello, world!", string(contents))
+	})
+
+	t.Run("rejects symbolic links to targets outside of the output directory", func(t *testing.T) {
+		err := os.Mkdir(outputDirPath, 0755)
+		assert.NoError(t, err)
+		defer os.RemoveAll(outputDirPath)
+
+		dirPath := filepath.Join(t.TempDir(), "escape")
+		assert.NoError(t, os.Mkdir(dirPath, 0755))
+		assert.NoError(t, os.Symlink("../../../etc/passwd", filepath.Join(dirPath, "passwd")))
+
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		archiver, err := NewArchiver(archive, ArchiverStoreSymlinks())
+		assert.NoError(t, err)
+		err = archiver.Archive(context.Background(), []string{dirPath})
+		assert.NoError(t, err)
+		archiver.Close()
+
+		extractor, err := NewExtractor(outputDirPath)
+		assert.NoError(t, err)
+		defer extractor.Close()
+
+		err = extractor.Extract(context.Background(), archive.Name())
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects chains of symbolic links leading outside of the output directory", func(t *testing.T) {
+		parentDir := t.TempDir()
+		outputDir := filepath.Join(parentDir, "output")
+		archivePath := filepath.Join(parentDir, "chain.zip")
+		writeEntries(t, archivePath, []testEntry{
+			{name: "b", contents: ".", mode: fs.ModeSymlink | 0777},
+			{name: "a", contents: "b/..", mode: fs.ModeSymlink | 0777},
+			{name: "a/escaped.txt", contents: "hello, world!", mode: 0644},
+		})
+
+		extractor, err := NewExtractor(outputDir)
+		assert.NoError(t, err)
+		defer extractor.Close()
+
+		err = extractor.Extract(context.Background(), archivePath)
+		assert.Error(t, err)
+
+		_, err = os.Stat(filepath.Join(parentDir, "escaped.txt"))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("doesn't write through existing symbolic links leading outside of the output directory", func(t *testing.T) {
+		parentDir := t.TempDir()
+		outputDir := filepath.Join(parentDir, "output")
+		assert.NoError(t, os.Mkdir(outputDir, 0755))
+		assert.NoError(t, os.Symlink("..", filepath.Join(outputDir, "parent")))
+		archivePath := filepath.Join(parentDir, "escape.zip")
+		writeEntries(t, archivePath, []testEntry{{name: "parent/escaped.txt", contents: "hello, world!", mode: 0644}})
+
+		extractor, err := NewExtractor(outputDir)
+		assert.NoError(t, err)
+		defer extractor.Close()
+
+		err = extractor.Extract(context.Background(), archivePath)
+		assert.Error(t, err)
+
+		_, err = os.Stat(filepath.Join(parentDir, "escaped.txt"))
+		assert.True(t, os.IsNotExist(err))
+	})
+}
+
+type testEntry struct {
+	name     string
+	contents string
+	mode     fs.FileMode
+}
+
+// writeEntries writes an archive of entries, in order, to path.
+func writeEntries(t testing.TB, path string, entries []testEntry) {
+	t.Helper()
+
+	archive, err := os.Create(path)
+	assert.NoError(t, err)
+	defer archive.Close()
+
+	w := zip.NewWriter(archive)
+	for _, e := range entries {
+		header := &zip.FileHeader{Name: e.name, Method: zip.Store}
+		header.SetMode(e.mode)
+		fw, err := w.CreateHeader(header)
+		assert.NoError(t, err)
+		_, err = fw.Write([]byte(e.contents))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, w.Close())
 }
@@ -0,0 +1,35 @@
+package pzip
+
+import (
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// A filter decides which files are archived by matching their archive names against
+// doublestar glob patterns, such as "**/*.log" or "hello/nested/**".
+type filter struct {
+	includes []string
+	excludes []string
+}
+
+// excluded reports whether the file with the given archive name matches an exclude pattern.
+// Directories are also matched with a trailing slash, so that patterns such as "**/build/" only
+// exclude directories.
+func (f *filter) excluded(name string, isDir bool) bool {
+	return matchAny(f.excludes, name, isDir)
+}
+
+// included reports whether the file with the given archive name matches an include pattern.
+// All files are included when there are no include patterns.
+func (f *filter) included(name string, isDir bool) bool {
+	return len(f.includes) == 0 || matchAny(f.includes, name, isDir)
+}
+
+func matchAny(patterns []string, name string, isDir bool) bool {
+	for _, pattern := range patterns {
+		if doublestar.MatchUnvalidated(pattern, name) || (isDir && doublestar.MatchUnvalidated(pattern, name+"/")) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,78 @@
+package pzip
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/ybirader/pzip/internal/testutils"
+)
+
+func TestFilter(t *testing.T) {
+	t.Run("excludes files and directories matching a pattern", func(t *testing.T) {
+		f := filter{excludes: []string{"**/*.log", "**/node_modules/"}}
+
+		assert.True(t, f.excluded("app/debug.log", false))
+		assert.True(t, f.excluded("app/node_modules", true))
+		assert.False(t, f.excluded("app/node_modules", false))
+		assert.False(t, f.excluded("app/main.go", false))
+	})
+
+	t.Run("includes all files when there are no include patterns", func(t *testing.T) {
+		f := filter{}
+
+		assert.True(t, f.included("app/main.go", false))
+	})
+
+	t.Run("includes only files matching a pattern", func(t *testing.T) {
+		f := filter{includes: []string{"**/*.go"}}
+
+		assert.True(t, f.included("app/cmd/main.go", false))
+		assert.False(t, f.included("app/README.md", false))
+	})
+}
+
+func TestArchiveFiltered(t *testing.T) {
+	t.Run("skips excluded directories and their contents", func(t *testing.T) {
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		archiver, err := NewArchiver(archive, ArchiverExclude("hello/nested"))
+		assert.NoError(t, err)
+		err = archiver.Archive(context.Background(), []string{helloDirectoryFixture})
+		assert.NoError(t, err)
+		archiver.Close()
+
+		archiveReader := testutils.GetArchiveReader(t, archive.Name())
+		defer archiveReader.Close()
+
+		assert.Equal(t, 2, len(archiveReader.File))
+		testutils.AssertArchiveContainsFile(t, archiveReader.File, "hello/")
+		testutils.AssertArchiveContainsFile(t, archiveReader.File, "hello/hello.txt")
+	})
+
+	t.Run("archives only included files", func(t *testing.T) {
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		archiver, err := NewArchiver(archive, ArchiverInclude("**/*.md"))
+		assert.NoError(t, err)
+		err = archiver.Archive(context.Background(), []string{helloDirectoryFixture, helloTxtFileFixture})
+		assert.NoError(t, err)
+		archiver.Close()
+
+		archiveReader := testutils.GetArchiveReader(t, archive.Name())
+		defer archiveReader.Close()
+
+		assert.Equal(t, 1, len(archiveReader.File))
+		testutils.AssertArchiveContainsFile(t, archiveReader.File, "hello/nested/hello.md")
+	})
+
+	t.Run("returns an error for malformed patterns", func(t *testing.T) {
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		_, err := NewArchiver(archive, ArchiverExclude("hello/["))
+		assert.Error(t, err)
+	})
+}
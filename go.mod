@@ -4,11 +4,15 @@ go 1.21
 
 require (
 	github.com/alecthomas/assert/v2 v2.3.0
+	github.com/bmatcuk/doublestar/v4 v4.10.0
 	github.com/klauspost/compress v1.16.7
+	golang.org/x/crypto v0.14.0
 	golang.org/x/sync v0.3.0
+	golang.org/x/term v0.13.0
 )
 
 require (
 	github.com/alecthomas/repr v0.2.0 // indirect
 	github.com/hexops/gotextdiff v1.0.3 // indirect
+	golang.org/x/sys v0.13.0 // indirect
 )
@@ -0,0 +1,197 @@
+package pzip
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+const gitignore = ".gitignore"
+
+// DefaultIgnoreFiles are the names of the ignore files honored by default.
+var DefaultIgnoreFiles = []string{gitignore, ".pzipignore"}
+
+// An ignorePattern is a single rule of an ignore file, converted to a doublestar pattern
+// which is matched against paths relative to the directory of the ignore file.
+type ignorePattern struct {
+	pattern string
+	negate  bool
+	dirOnly bool
+}
+
+func (p *ignorePattern) matches(path string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+
+	return doublestar.MatchUnvalidated(p.pattern, path)
+}
+
+// An ignorer decides which files are ignored while walking a directory tree, using ignore files in
+// .gitignore syntax found in the tree. The patterns of an ignore file apply to its directory and all
+// directories beneath it, with patterns in deeper ignore files taking precedence.
+type ignorer struct {
+	names    []string
+	root     string
+	patterns map[string][]ignorePattern // by directory
+	open     func(name string) (fs.File, error)
+}
+
+// newIgnorer returns an ignorer for ignore files with the given names, which are opened using open.
+func newIgnorer(names []string, open func(name string) (fs.File, error)) *ignorer {
+	return &ignorer{names: names, patterns: make(map[string][]ignorePattern), open: open}
+}
+
+// reset prepares the ignorer to walk the directory tree at root.
+func (i *ignorer) reset(root string) {
+	i.root = filepath.Clean(root)
+	clear(i.patterns)
+}
+
+// load reads the ignore files in dir, if any. Directories must be loaded before their contents
+// are checked with ignored.
+func (i *ignorer) load(dir string) error {
+	dir = filepath.Clean(dir)
+
+	for _, name := range i.names {
+		path := filepath.Join(dir, name)
+
+		patterns, err := i.read(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("read ignore file %q: %w", path, err)
+		}
+
+		i.patterns[dir] = append(i.patterns[dir], patterns...)
+	}
+
+	return nil
+}
+
+// ignored reports whether the file at path is ignored. Like git, the last matching pattern decides,
+// so negated patterns can re-include files that an earlier pattern ignored.
+func (i *ignorer) ignored(path string, isDir bool) bool {
+	path = filepath.Clean(path)
+	if path == i.root {
+		return false
+	}
+
+	if isDir && filepath.Base(path) == ".git" && i.honorsGitignore() {
+		return true
+	}
+
+	ignored := false
+	for _, dir := range i.ancestors(path) {
+		relativePath, err := filepath.Rel(dir, path)
+		if err != nil {
+			continue
+		}
+		relativePath = filepath.ToSlash(relativePath)
+
+		for _, pattern := range i.patterns[dir] {
+			if pattern.matches(relativePath, isDir) {
+				ignored = !pattern.negate
+			}
+		}
+	}
+
+	return ignored
+}
+
+func (i *ignorer) honorsGitignore() bool {
+	for _, name := range i.names {
+		if name == gitignore {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ancestors returns the directories from the root down to the parent of path.
+func (i *ignorer) ancestors(path string) []string {
+	var dirs []string
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if dir == i.root || dir == filepath.Dir(dir) {
+			break
+		}
+	}
+
+	for l, r := 0, len(dirs)-1; l < r; l, r = l+1, r-1 {
+		dirs[l], dirs[r] = dirs[r], dirs[l]
+	}
+
+	return dirs
+}
+
+func (i *ignorer) read(path string) ([]ignorePattern, error) {
+	f, err := i.open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var patterns []ignorePattern
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if pattern, ok := parseIgnorePattern(scanner.Text()); ok {
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return patterns, nil
+}
+
+// parseIgnorePattern parses a line of an ignore file, following the rules of .gitignore files
+// (See https://git-scm.com/docs/gitignore). It returns false for blank lines, comments and invalid patterns.
+func parseIgnorePattern(line string) (ignorePattern, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignorePattern{}, false
+	}
+
+	var p ignorePattern
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\#`) || strings.HasPrefix(line, `\!`) {
+		// escaped patterns match names starting with a literal # or !
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+
+	// patterns containing a slash are relative to the directory of the ignore file,
+	// otherwise they match at any depth
+	if strings.HasPrefix(line, "/") {
+		line = line[1:]
+	} else if !strings.Contains(line, "/") {
+		line = "**/" + line
+	}
+
+	if line == "" || !doublestar.ValidatePattern(line) {
+		return ignorePattern{}, false
+	}
+
+	p.pattern = line
+	return p, true
+}
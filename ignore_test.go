@@ -0,0 +1,99 @@
+package pzip
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/ybirader/pzip/internal/testutils"
+)
+
+func TestParseIgnorePattern(t *testing.T) {
+	cases := []struct {
+		line string
+		want ignorePattern
+		ok   bool
+	}{
+		{"", ignorePattern{}, false},
+		{"# comment", ignorePattern{}, false},
+		{"*.log", ignorePattern{pattern: "**/*.log"}, true},
+		{"*.log  ", ignorePattern{pattern: "**/*.log"}, true},
+		{"!keep.log", ignorePattern{pattern: "**/keep.log", negate: true}, true},
+		{"build/", ignorePattern{pattern: "**/build", dirOnly: true}, true},
+		{"/secret.txt", ignorePattern{pattern: "secret.txt"}, true},
+		{"docs/*.md", ignorePattern{pattern: "docs/*.md"}, true},
+		{`\#hash`, ignorePattern{pattern: "**/#hash"}, true},
+		{`\!bang`, ignorePattern{pattern: "**/!bang"}, true},
+		{`!\!bang`, ignorePattern{pattern: `**/\!bang`, negate: true}, true},
+	}
+
+	for _, c := range cases {
+		got, ok := parseIgnorePattern(c.line)
+		assert.Equal(t, c.ok, ok, c.line)
+		assert.Equal(t, c.want, got, c.line)
+	}
+
+	for _, name := range []string{"#hash", "!bang"} {
+		p, ok := parseIgnorePattern(`\` + name)
+		assert.True(t, ok, name)
+		assert.True(t, p.matches("dir/"+name, false), name)
+	}
+}
+
+func TestArchiveIgnoreFiles(t *testing.T) {
+	t.Run("skips files ignored by ignore files", func(t *testing.T) {
+		dirPath := filepath.Join(t.TempDir(), "repo")
+		writeFiles(t, dirPath, map[string]string{
+			".gitignore":     "*.log\nbuild/\n!keep.log\n/secret.txt\n",
+			".git/HEAD":      "ref: refs/heads/main",
+			"a.log":          "",
+			"keep.log":       "",
+			"secret.txt":     "",
+			"main.go":        "",
+			"build/out.bin":  "",
+			"sub/.gitignore": "*.go\n",
+			"sub/secret.txt": "",
+			"sub/main.go":    "",
+			"sub/debug.log":  "",
+		})
+
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		archiver, err := NewArchiver(archive, ArchiverIgnoreFiles())
+		assert.NoError(t, err)
+		err = archiver.Archive(context.Background(), []string{dirPath})
+		assert.NoError(t, err)
+		archiver.Close()
+
+		archiveReader := testutils.GetArchiveReader(t, archive.Name())
+		defer archiveReader.Close()
+
+		names := testutils.Map(archiveReader.File, func(file *zip.File) string { return file.Name })
+		sort.Strings(names)
+
+		assert.Equal(t, []string{
+			"repo/",
+			"repo/.gitignore",
+			"repo/keep.log",
+			"repo/main.go",
+			"repo/sub/",
+			"repo/sub/.gitignore",
+			"repo/sub/secret.txt",
+		}, names)
+	})
+}
+
+func writeFiles(t testing.TB, dirPath string, files map[string]string) {
+	t.Helper()
+
+	for name, contents := range files {
+		path := filepath.Join(dirPath, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte(contents), 0644))
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package pzip
+
+import "io/fs"
+
+// fileOwner returns false, as files don't have Unix owners on this operating system.
+func fileOwner(info fs.FileInfo) (uid, gid uint32, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,110 @@
+//go:build unix
+
+package pzip
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/ybirader/pzip/internal/testutils"
+)
+
+func TestUnixExtraField(t *testing.T) {
+	t.Run("parses an encoded field among other fields", func(t *testing.T) {
+		extra := NewExtendedTimestampExtraField(time.Now()).Encode()
+		extra = append(extra, NewUnixExtraField(1000, 100).Encode()...)
+
+		got, ok := ParseUnixExtraField(extra)
+		assert.True(t, ok)
+		assert.Equal(t, NewUnixExtraField(1000, 100), got)
+	})
+
+	t.Run("parses IDs of other sizes", func(t *testing.T) {
+		extra := []byte{0x75, 0x78, 8, 0, 1, 2, 0xe8, 0x03, 3, 0x64, 0, 0}
+
+		got, ok := ParseUnixExtraField(extra)
+		assert.True(t, ok)
+		assert.Equal(t, NewUnixExtraField(1000, 100), got)
+	})
+
+	t.Run("returns false without a field or for IDs which don't fit in 32 bits", func(t *testing.T) {
+		_, ok := ParseUnixExtraField(NewExtendedTimestampExtraField(time.Now()).Encode())
+		assert.False(t, ok)
+
+		extra := []byte{0x75, 0x78, 13, 0, 1, 8, 0, 0, 0, 0, 1, 0, 0, 0, 1, 0, 0}
+		_, ok = ParseUnixExtraField(extra)
+		assert.False(t, ok)
+	})
+}
+
+func TestArchiverOwner(t *testing.T) {
+	t.Run("stores the owner of files", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "archive.zip")
+		createArchive(t, path, []string{helloTxtFileFixture})
+
+		archiveReader := testutils.GetArchiveReader(t, path)
+		defer archiveReader.Close()
+
+		info, err := os.Stat(helloTxtFileFixture)
+		assert.NoError(t, err)
+		stat := info.Sys().(*syscall.Stat_t)
+
+		owner, ok := ParseUnixExtraField(archiveReader.File[0].Extra)
+		assert.True(t, ok)
+		assert.Equal(t, NewUnixExtraField(stat.Uid, stat.Gid), owner)
+	})
+
+	t.Run("doesn't store the owner of files of reproducible archives", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "archive.zip")
+		createArchive(t, path, []string{helloTxtFileFixture}, ArchiverReproducible(time.Time{}))
+
+		archiveReader := testutils.GetArchiveReader(t, path)
+		defer archiveReader.Close()
+
+		_, ok := ParseUnixExtraField(archiveReader.File[0].Extra)
+		assert.False(t, ok)
+	})
+}
+
+func TestExtractOwner(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("restoring owners requires running as root")
+	}
+
+	path := filepath.Join(t.TempDir(), "archive.zip")
+	archive, err := os.Create(path)
+	assert.NoError(t, err)
+
+	archiver, err := NewArchiver(archive)
+	assert.NoError(t, err)
+	header := &zip.FileHeader{Name: "owned.txt", Method: zip.Deflate, Extra: NewUnixExtraField(1234, 5678).Encode()}
+	assert.NoError(t, archiver.AddReader(header, bytes.NewReader([]byte("owned"))))
+	assert.NoError(t, archiver.Archive(context.Background(), nil))
+	assert.NoError(t, archiver.Close())
+	assert.NoError(t, archive.Close())
+
+	t.Run("restores the owner of files", func(t *testing.T) {
+		outputDir := extractArchive(t, path, ExtractorOwnership())
+
+		info, err := os.Stat(filepath.Join(outputDir, "owned.txt"))
+		assert.NoError(t, err)
+		stat := info.Sys().(*syscall.Stat_t)
+		assert.Equal(t, uint32(1234), stat.Uid)
+		assert.Equal(t, uint32(5678), stat.Gid)
+	})
+
+	t.Run("doesn't restore owners unless configured to", func(t *testing.T) {
+		outputDir := extractArchive(t, path)
+
+		info, err := os.Stat(filepath.Join(outputDir, "owned.txt"))
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(os.Geteuid()), info.Sys().(*syscall.Stat_t).Uid)
+	})
+}
@@ -0,0 +1,19 @@
+//go:build unix
+
+package pzip
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileOwner returns the user and group IDs of the owner of the file described by info. It returns false
+// if they aren't known, such as for files which aren't read from the operating system's file system.
+func fileOwner(info fs.FileInfo) (uid, gid uint32, ok bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+
+	return stat.Uid, stat.Gid, true
+}
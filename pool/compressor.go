@@ -0,0 +1,62 @@
+package pool
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/zstd"
+)
+
+// A Compressor compresses data written to it and writes the compressed form to an underlying writer.
+// Reset discards any state and makes the compressor write to w.
+type Compressor interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+// NewCompressor returns a Compressor for the zip method, writing to w. The level ranges from
+// flate.NoCompression to flate.BestCompression, or is flate.DefaultCompression. For Zstandard, the level
+// is mapped to the closest encoder level. An error is returned if the method or level is not supported.
+func NewCompressor(w io.Writer, method uint16, level int) (Compressor, error) {
+	switch method {
+	case zip.Store:
+		return &storeCompressor{w}, nil
+	case zip.Deflate:
+		compressor, err := flate.NewWriter(w, level)
+		if err != nil {
+			return nil, fmt.Errorf("new flate writer: %w", err)
+		}
+		return compressor, nil
+	case zstd.ZipMethodWinZip:
+		encoderLevel := zstd.SpeedDefault
+		if level != flate.DefaultCompression {
+			encoderLevel = zstd.EncoderLevelFromZstd(level)
+		}
+		compressor, err := zstd.NewWriter(w, zstd.WithEncoderConcurrency(1), zstd.WithEncoderLevel(encoderLevel))
+		if err != nil {
+			return nil, fmt.Errorf("new zstd writer: %w", err)
+		}
+		return compressor, nil
+	default:
+		return nil, fmt.Errorf("unsupported compression method %d", method)
+	}
+}
+
+// storeCompressor writes data to the underlying writer as is.
+type storeCompressor struct {
+	w io.Writer
+}
+
+func (s *storeCompressor) Write(p []byte) (int, error) {
+	return s.w.Write(p)
+}
+
+func (s *storeCompressor) Close() error {
+	return nil
+}
+
+func (s *storeCompressor) Reset(w io.Writer) {
+	s.w = w
+}
@@ -4,12 +4,12 @@ import (
 	"archive/zip"
 	"bytes"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"sync"
-
-	"github.com/klauspost/compress/flate"
+	"time"
 )
 
 const DefaultBufferSize = 2 * 1024 * 1024
@@ -26,9 +26,15 @@ type File struct {
 	Header         *zip.FileHeader
 	CompressedData *bytes.Buffer
 	Overflow       *os.File
-	Compressor     *flate.Writer
+	Compressor     Compressor
+	Reader         io.Reader // contents of the file, if not read from Path
+	Source         *zip.File // unchanged entry of an existing archive, copied as is rather than compressed
 	Path           string
+	Sequence       int           // position in which the file was enqueued, when entries are written in order
+	Elapsed        time.Duration // time spent compressing the file
 	written        int64
+	method         uint16
+	level          int
 }
 
 func NewFile(path string, info fs.FileInfo, relativeTo string) (*File, error) {
@@ -37,27 +43,21 @@ func NewFile(path string, info fs.FileInfo, relativeTo string) (*File, error) {
 	return f, err
 }
 
+// NewReaderFile returns a file with contents read from r, rather than from the file system.
+// The file is named and described by a copy of header.
+func NewReaderFile(header *zip.FileHeader, r io.Reader) *File {
+	f := FilePool.Get().(*File)
+	f.ResetReader(header, r)
+	return f
+}
+
 // Reset resets the file-backed buffer ready to be used by another file.
 func (f *File) Reset(path string, info fs.FileInfo, relativeTo string) error {
 	hdr, err := zip.FileInfoHeader(info)
 	if err != nil {
 		return fmt.Errorf("file info header for %q: %w", path, err)
 	}
-	f.Path = path
-	f.Info = info
-	f.Header = hdr
-	f.CompressedData.Reset()
-	f.Overflow = nil
-	f.written = 0
-
-	if f.Compressor == nil {
-		f.Compressor, err = flate.NewWriter(f, flate.DefaultCompression)
-		if err != nil {
-			return fmt.Errorf("new compressor: %w", err)
-		}
-	} else {
-		f.Compressor.Reset(f)
-	}
+	f.reset(path, info, hdr, nil)
 
 	if relativeTo != "" {
 		if err := f.setNameRelativeTo(relativeTo); err != nil {
@@ -68,7 +68,34 @@ func (f *File) Reset(path string, info fs.FileInfo, relativeTo string) error {
 	return nil
 }
 
+// ResetReader resets the file-backed buffer ready to be used by a file with contents read from r.
+func (f *File) ResetReader(header *zip.FileHeader, r io.Reader) {
+	hdr := *header
+	f.reset(hdr.Name, hdr.FileInfo(), &hdr, r)
+}
+
+func (f *File) reset(path string, info fs.FileInfo, header *zip.FileHeader, r io.Reader) {
+	f.Path = path
+	f.Info = info
+	f.Header = header
+	f.Reader = r
+	f.Source = nil
+	f.Sequence = 0
+	f.Elapsed = 0
+	f.CompressedData.Reset()
+	f.Overflow = nil
+	f.written = 0
+
+	if f.Compressor != nil {
+		f.Compressor.Reset(f)
+	}
+}
+
+// Write writes p to the in-memory buffer, writing what doesn't fit to a temporary file. It returns the number
+// of bytes of p written, including those written to the buffer before any error writing the temporary file.
 func (f *File) Write(p []byte) (n int, err error) {
+	n = len(p)
+
 	if f.CompressedData.Available() != 0 {
 		maxWriteable := min(f.CompressedData.Available(), len(p))
 		f.written += int64(maxWriteable)
@@ -79,17 +106,35 @@ func (f *File) Write(p []byte) (n int, err error) {
 	if len(p) > 0 {
 		if f.Overflow == nil {
 			if f.Overflow, err = os.CreateTemp("", "pzip-overflow"); err != nil {
-				return len(p), fmt.Errorf("create temporary file: %w", err)
+				return n - len(p), fmt.Errorf("create temporary file: %w", err)
 			}
 		}
 
 		if _, err := f.Overflow.Write(p); err != nil {
-			return len(p), fmt.Errorf("write temporary file for %q: %w", f.Header.Name, err)
+			return n - len(p), fmt.Errorf("write temporary file for %q: %w", f.Header.Name, err)
 		}
 		f.written += int64(len(p))
 	}
 
-	return len(p), nil
+	return n, nil
+}
+
+// SetCompression sets the zip method and level used to compress the file. The compressor of the file is
+// only replaced if it doesn't already use method and level.
+func (f *File) SetCompression(method uint16, level int) error {
+	if f.Compressor != nil && f.method == method && f.level == level {
+		return nil
+	}
+
+	compressor, err := NewCompressor(f, method, level)
+	if err != nil {
+		return fmt.Errorf("new compressor: %w", err)
+	}
+	f.Compressor = compressor
+	f.method = method
+	f.level = level
+
+	return nil
 }
 
 // Written returns the number of bytes of the file compressed and written to a destination
@@ -1,10 +1,16 @@
 package pool_test
 
 import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/alecthomas/assert/v2"
+	"github.com/klauspost/compress/flate"
 	"github.com/ybirader/pzip/internal/testutils"
 	"github.com/ybirader/pzip/pool"
 )
@@ -63,4 +69,87 @@ func TestNewFile(t *testing.T) {
 		assert.Equal(t, 0, file.CompressedData.Len())
 		assert.Equal(t, pool.DefaultBufferSize, file.CompressedData.Cap())
 	})
+	t.Run("reuses compressor when method and level are unchanged", func(t *testing.T) {
+		info := testutils.GetFileInfo(t, helloTxtFileFixture)
+		file, err := pool.NewFile(helloTxtFileFixture, info, "")
+		assert.NoError(t, err)
+
+		err = file.SetCompression(zip.Deflate, flate.BestSpeed)
+		assert.NoError(t, err)
+		compressor := file.Compressor
+
+		err = file.SetCompression(zip.Deflate, flate.BestSpeed)
+		assert.NoError(t, err)
+		assert.True(t, compressor == file.Compressor)
+
+		err = file.SetCompression(zip.Deflate, flate.BestCompression)
+		assert.NoError(t, err)
+		assert.False(t, compressor == file.Compressor)
+		compressor = file.Compressor
+
+		err = file.SetCompression(zip.Store, flate.BestCompression)
+		assert.NoError(t, err)
+		assert.False(t, compressor == file.Compressor)
+	})
+
+	t.Run("returns an error for unsupported methods", func(t *testing.T) {
+		info := testutils.GetFileInfo(t, helloTxtFileFixture)
+		file, err := pool.NewFile(helloTxtFileFixture, info, "")
+		assert.NoError(t, err)
+
+		err = file.SetCompression(99, flate.DefaultCompression)
+		assert.Error(t, err)
+	})
+
+	t.Run("returns an error for unsupported levels", func(t *testing.T) {
+		info := testutils.GetFileInfo(t, helloTxtFileFixture)
+		file, err := pool.NewFile(helloTxtFileFixture, info, "")
+		assert.NoError(t, err)
+
+		err = file.SetCompression(zip.Deflate, 10)
+		assert.Error(t, err)
+	})
+}
+
+func TestNewReaderFile(t *testing.T) {
+	t.Run("describes the file using a copy of the header", func(t *testing.T) {
+		header := &zip.FileHeader{Name: "manifest.json"}
+		r := strings.NewReader("{}")
+
+		file := pool.NewReaderFile(header, r)
+
+		assert.Equal(t, "manifest.json", file.Path)
+		assert.Equal(t, "manifest.json", file.Header.Name)
+		assert.False(t, header == file.Header)
+		assert.False(t, file.Info.IsDir())
+		assert.True(t, file.Reader == io.Reader(r))
+	})
+
+	t.Run("clears the reader when reset", func(t *testing.T) {
+		file := pool.NewReaderFile(&zip.FileHeader{Name: "manifest.json"}, strings.NewReader("{}"))
+
+		err := file.Reset(helloTxtFileFixture, testutils.GetFileInfo(t, helloTxtFileFixture), "")
+		assert.NoError(t, err)
+
+		assert.Zero(t, file.Reader)
+	})
+}
+
+func TestFileWrite(t *testing.T) {
+	t.Run("reports all bytes written when contents overflow", func(t *testing.T) {
+		info := testutils.GetFileInfo(t, helloTxtFileFixture)
+		file, err := pool.NewFile(helloTxtFileFixture, info, "")
+		assert.NoError(t, err)
+		file.CompressedData = bytes.NewBuffer(make([]byte, 0, 2))
+		defer func() {
+			file.Overflow.Close()
+			os.Remove(file.Overflow.Name())
+		}()
+
+		n, err := file.Write([]byte("hello"))
+		assert.NoError(t, err)
+
+		assert.Equal(t, 5, n)
+		assert.True(t, file.Overflowed())
+	})
 }
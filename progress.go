@@ -0,0 +1,136 @@
+package pzip
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Progress describes how far archiving or extraction has got. When archiving, totals grow as files are found
+// and are only final once Counted is set, after all files have been found. When extracting, totals are known
+// from the start.
+type Progress struct {
+	TotalFiles      int   // files to archive or extract
+	TotalBytes      int64 // uncompressed size of the files to archive or extract
+	Counted         bool  // whether all files have been found, so that the totals are final
+	FilesCompressed int   // files compressed, or extracted when extracting
+	FilesWritten    int   // entries written to the archive, or files extracted when extracting
+	BytesRead       int64 // uncompressed bytes read from files, or from entries when extracting
+	BytesCompressed int64 // compressed size of the compressed files, or of the extracted entries when extracting
+	BytesWritten    int64 // bytes of entries written to the archive, or to extracted files when extracting
+}
+
+// progressInterval is the minimum interval between reports of progress, other than the final report.
+const progressInterval = 100 * time.Millisecond
+
+// A progressTracker keeps track of progress, reporting it at most once per progressInterval, and once more when
+// finished. A nil tracker doesn't track anything, so that progress is only kept track of if it's reported. Bytes
+// read and written are counted separately, so that reading and writing files doesn't take the lock.
+type progressTracker struct {
+	mu           sync.Mutex
+	progress     Progress
+	report       func(progress Progress)
+	now          func() time.Time
+	bytesRead    atomic.Int64
+	bytesWritten atomic.Int64
+	reported     atomic.Int64 // time of the last report, in nanoseconds since the Unix epoch
+}
+
+func newProgressTracker(report func(progress Progress)) *progressTracker {
+	return &progressTracker{report: report, now: time.Now}
+}
+
+// update updates the progress using f, then reports it if it's due. Reports are serialized, so report doesn't
+// need to be safe for concurrent use.
+func (p *progressTracker) update(f func(progress *Progress)) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	f(&p.progress)
+	p.mu.Unlock()
+
+	p.reportIfDue()
+}
+
+// finish reports the progress, whether or not a report is due, so that the final progress is always reported.
+func (p *progressTracker) finish() {
+	if p == nil {
+		return
+	}
+
+	p.reported.Store(p.now().UnixNano())
+	p.reportProgress()
+}
+
+// reportIfDue reports the progress if progressInterval has passed since the last report. Of the goroutines
+// updating progress at the same time, only one reports it.
+func (p *progressTracker) reportIfDue() {
+	now := p.now().UnixNano()
+	reported := p.reported.Load()
+	if now-reported < int64(progressInterval) || !p.reported.CompareAndSwap(reported, now) {
+		return
+	}
+
+	p.reportProgress()
+}
+
+func (p *progressTracker) reportProgress() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	progress := p.progress
+	progress.BytesRead += p.bytesRead.Load()
+	progress.BytesWritten += p.bytesWritten.Load()
+	p.report(progress)
+}
+
+// reader returns a reader which adds the bytes read from r to the bytes read.
+func (p *progressTracker) reader(r io.Reader) io.Reader {
+	if p == nil {
+		return r
+	}
+
+	return &progressReader{r: r, progress: p}
+}
+
+// writer returns a writer which adds the bytes written to w to the bytes written.
+func (p *progressTracker) writer(w io.Writer) io.Writer {
+	if p == nil {
+		return w
+	}
+
+	return &progressWriter{w: w, progress: p}
+}
+
+type progressReader struct {
+	r        io.Reader
+	progress *progressTracker
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	if n > 0 {
+		r.progress.bytesRead.Add(int64(n))
+		r.progress.reportIfDue()
+	}
+
+	return n, err
+}
+
+type progressWriter struct {
+	w        io.Writer
+	progress *progressTracker
+}
+
+func (w *progressWriter) Write(b []byte) (int, error) {
+	n, err := w.w.Write(b)
+	if n > 0 {
+		w.progress.bytesWritten.Add(int64(n))
+		w.progress.reportIfDue()
+	}
+
+	return n, err
+}
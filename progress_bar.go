@@ -0,0 +1,99 @@
+package pzip
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	progressBarWidth    = 30
+	progressBarInterval = 200 * time.Millisecond
+)
+
+// A ProgressBar renders progress to a terminal, on a single line showing how many of the files and bytes to
+// archive or extract have been read, along with the throughput and the estimated time remaining. Its Update
+// method can be passed to ArchiverProgress and ExtractorProgress. Renders are throttled, so that progress can
+// be updated often.
+type ProgressBar struct {
+	w        io.Writer
+	start    time.Time
+	rendered time.Time
+	progress Progress
+	now      func() time.Time
+}
+
+// NewProgressBar returns a progress bar rendering to w, which should be a terminal, such as os.Stderr.
+func NewProgressBar(w io.Writer) *ProgressBar {
+	return &ProgressBar{w: w, start: time.Now(), now: time.Now}
+}
+
+// Update renders progress, unless progress was rendered less than a fraction of a second ago.
+func (b *ProgressBar) Update(progress Progress) {
+	b.progress = progress
+
+	now := b.now()
+	if now.Sub(b.rendered) < progressBarInterval {
+		return
+	}
+
+	b.rendered = now
+	b.render(now)
+}
+
+// Finish renders the last progress given to Update and ends the line of the progress bar, so that
+// following output starts on a new line.
+func (b *ProgressBar) Finish() {
+	b.render(b.now())
+	fmt.Fprintln(b.w)
+}
+
+func (b *ProgressBar) render(now time.Time) {
+	elapsed := now.Sub(b.start)
+	p := b.progress
+
+	var throughput float64
+	if elapsed > 0 {
+		throughput = float64(p.BytesRead) / elapsed.Seconds()
+	}
+
+	var line string
+	if p.Counted && p.TotalBytes > 0 {
+		fraction := min(float64(p.BytesRead)/float64(p.TotalBytes), 1)
+		filled := int(fraction * progressBarWidth)
+		bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled)
+
+		eta := "--"
+		if throughput > 0 {
+			remaining := time.Duration(float64(p.TotalBytes-p.BytesRead) / throughput * float64(time.Second))
+			eta = remaining.Round(time.Second).String()
+		}
+
+		line = fmt.Sprintf("[%s] %3.0f%%  %d/%d files  %s/%s  %s/s  ETA %s", bar, fraction*100, p.FilesWritten,
+			p.TotalFiles, formatBytes(p.BytesRead), formatBytes(p.TotalBytes), formatBytes(int64(throughput)), eta)
+	} else {
+		// the totals aren't known while files are still being found
+		line = fmt.Sprintf("%d/%d files  %s/%s  %s/s", p.FilesWritten, p.TotalFiles, formatBytes(p.BytesRead),
+			formatBytes(p.TotalBytes), formatBytes(int64(throughput)))
+	}
+
+	// the line is cleared to its end, as it may be shorter than the previous one
+	fmt.Fprintf(b.w, "\r%s\033[K", line)
+}
+
+// formatBytes formats n bytes using binary units, such as 1.5 GiB.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
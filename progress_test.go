@@ -0,0 +1,146 @@
+package pzip
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestArchiverProgress(t *testing.T) {
+	dir := t.TempDir()
+	large := make([]byte, 3*defaultBlockSize)
+	_, err := rand.Read(large)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "large.bin"), large, 0644))
+	writeFiles(t, dir, map[string]string{"a.txt": "a", "b.txt": strings.Repeat("b", 1000)})
+
+	var reports []Progress
+	path := filepath.Join(t.TempDir(), "archive.zip")
+	createArchive(t, path, []string{dir}, ArchiverConcurrency(4), ArchiverStoreCompressed(), ArchiverProgress(func(progress Progress) {
+		reports = append(reports, progress)
+	}))
+
+	t.Run("reports the totals once all files are found", func(t *testing.T) {
+		got := reports[len(reports)-1]
+		assert.True(t, got.Counted)
+		assert.Equal(t, 4, got.TotalFiles)
+		assert.Equal(t, int64(len(large)+1+1000), got.TotalBytes)
+		assert.Equal(t, got.TotalFiles, got.FilesCompressed)
+		assert.Equal(t, got.TotalFiles, got.FilesWritten)
+		assert.Equal(t, got.TotalBytes, got.BytesRead)
+	})
+
+	t.Run("reports the bytes compressed and written", func(t *testing.T) {
+		info, err := os.Stat(path)
+		assert.NoError(t, err)
+
+		got := reports[len(reports)-1]
+		assert.Equal(t, got.BytesCompressed, got.BytesWritten)
+		assertGreaterThan(t, info.Size(), got.BytesWritten)
+		assertGreaterThan(t, got.BytesWritten, int64(len(large)))
+	})
+
+	t.Run("reports progress as it's made", func(t *testing.T) {
+		for i := 1; i < len(reports); i++ {
+			assert.True(t, reports[i].FilesWritten >= reports[i-1].FilesWritten)
+			assert.True(t, reports[i].BytesWritten >= reports[i-1].BytesWritten)
+		}
+	})
+}
+
+func TestProgressTracker(t *testing.T) {
+	t.Run("throttles reports, counting bytes read and written in between", func(t *testing.T) {
+		var reports []Progress
+		now := time.Now()
+		tracker := newProgressTracker(func(progress Progress) {
+			reports = append(reports, progress)
+		})
+		tracker.now = func() time.Time { return now }
+
+		r := tracker.reader(strings.NewReader(strings.Repeat("a", 100)))
+		w := tracker.writer(io.Discard)
+		buf := make([]byte, 10)
+		for i := 0; i < 3; i++ {
+			_, err := r.Read(buf)
+			assert.NoError(t, err)
+			_, err = w.Write(buf)
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, []Progress{{BytesRead: 10}}, reports)
+
+		now = now.Add(progressInterval)
+		tracker.update(func(progress *Progress) {
+			progress.FilesWritten++
+		})
+		assert.Equal(t, Progress{FilesWritten: 1, BytesRead: 30, BytesWritten: 30}, reports[len(reports)-1])
+		assert.Equal(t, 2, len(reports))
+	})
+
+	t.Run("reports the final progress when finished", func(t *testing.T) {
+		var reports []Progress
+		now := time.Now()
+		tracker := newProgressTracker(func(progress Progress) {
+			reports = append(reports, progress)
+		})
+		tracker.now = func() time.Time { return now }
+
+		_, err := tracker.writer(io.Discard).Write(make([]byte, 10))
+		assert.NoError(t, err)
+		_, err = tracker.writer(io.Discard).Write(make([]byte, 10))
+		assert.NoError(t, err)
+		tracker.finish()
+
+		assert.Equal(t, []Progress{{BytesWritten: 10}, {BytesWritten: 20}}, reports)
+	})
+}
+
+func TestExtractorProgress(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.zip")
+	createArchive(t, path, []string{helloTxtFileFixture, helloDirectoryFixture})
+
+	var got Progress
+	extractArchive(t, path, ExtractorProgress(func(progress Progress) {
+		got = progress
+	}))
+
+	assert.True(t, got.Counted)
+	assert.Equal(t, got.TotalFiles, got.FilesWritten)
+	assert.Equal(t, got.TotalBytes, got.BytesRead)
+	assert.Equal(t, got.TotalBytes, got.BytesWritten)
+}
+
+func TestProgressBar(t *testing.T) {
+	t.Run("throttles renders", func(t *testing.T) {
+		var out bytes.Buffer
+		now := time.Now()
+		bar := NewProgressBar(&out)
+		bar.now = func() time.Time { return now }
+
+		bar.Update(Progress{TotalFiles: 2, TotalBytes: 100})
+		bar.Update(Progress{TotalFiles: 2, TotalBytes: 100, FilesWritten: 1, BytesRead: 50})
+		assert.Equal(t, 1, strings.Count(out.String(), "\r"))
+
+		now = now.Add(progressBarInterval)
+		bar.Update(Progress{TotalFiles: 2, TotalBytes: 100, FilesWritten: 1, BytesRead: 50})
+		assert.Equal(t, 2, strings.Count(out.String(), "\r"))
+	})
+
+	t.Run("renders the percentage, throughput and time remaining once totals are known", func(t *testing.T) {
+		var out bytes.Buffer
+		bar := NewProgressBar(&out)
+		bar.now = func() time.Time { return bar.start.Add(2 * time.Second) }
+
+		bar.Update(Progress{TotalFiles: 4, TotalBytes: 4 << 20, Counted: true, FilesWritten: 1, BytesRead: 1 << 20})
+		bar.Finish()
+
+		assert.Contains(t, out.String(), " 25%  1/4 files  1.0 MiB/4.0 MiB  512.0 KiB/s  ETA 6s")
+		assert.True(t, strings.HasSuffix(out.String(), "\n"))
+	})
+}
@@ -0,0 +1,65 @@
+package pzip
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/ybirader/pzip/pool"
+)
+
+// validateName returns an error if name, the destination of entries given to ArchiverPrefix or ArchiverRootNames,
+// is absolute or refers outside of the archive.
+func validateName(name string) error {
+	cleaned := path.Clean(name)
+	if path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return fmt.Errorf("name %q isn't relative to the archive", name)
+	}
+
+	return nil
+}
+
+// rename renames the entry of file, found while walking a root given to Archive. The name of the root, which
+// begins the names of entries, is replaced by the destination it's mapped to, if any, then the prefix is added
+// and the name is transformed by the rename function. It reports whether file is skipped, either by the rename
+// function or because it's a root directory whose contents are placed at the top of the archive.
+func (a *archiver) rename(file *pool.File) (skip bool) {
+	name := file.Header.Name
+
+	if a.rootMapped {
+		// names begin with the name of the root, except for those of files at the root of a file system
+		rest := name
+		if a.fsys == nil || a.chroot != "." {
+			_, rest, _ = strings.Cut(name, "/")
+		}
+		name = path.Join(a.rootName, rest)
+	}
+
+	if a.prefix != "" {
+		name = path.Join(a.prefix, name)
+	}
+
+	if name == "" || name == "." {
+		return true
+	}
+
+	if a.renameFunc != nil {
+		// names of directories end with a slash, so that they can be told apart from files
+		isDir := file.Info.IsDir()
+		if isDir {
+			name += "/"
+		}
+
+		if name, skip = a.renameFunc(name); skip || name == "" || name == "/" {
+			return true
+		}
+
+		if isDir {
+			// the slash is added back when populating the header
+			name = strings.TrimSuffix(name, "/")
+		}
+	}
+
+	file.Header.Name = name
+	return false
+}
@@ -0,0 +1,125 @@
+package pzip
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/ybirader/pzip/internal/testutils"
+)
+
+func TestArchiverRename(t *testing.T) {
+	t.Run("places entries under a prefix", func(t *testing.T) {
+		got := archivedNames(t, []string{helloTxtFileFixture, helloDirectoryFixture}, ArchiverPrefix("myapp-1.2/"))
+		assert.Equal(t, []string{
+			"myapp-1.2/hello.txt", "myapp-1.2/hello/", "myapp-1.2/hello/hello.txt",
+			"myapp-1.2/hello/nested/", "myapp-1.2/hello/nested/hello.md",
+		}, got)
+	})
+
+	t.Run("names the entries of roots after their destinations", func(t *testing.T) {
+		got := archivedNames(t, []string{helloTxtFileFixture, helloDirectoryFixture}, ArchiverPrefix("myapp"),
+			ArchiverRootNames(map[string]string{"./testdata/hello": "bin/out", helloTxtFileFixture: "greeting.txt"}))
+		assert.Equal(t, []string{
+			"myapp/bin/out/", "myapp/bin/out/hello.txt", "myapp/bin/out/nested/", "myapp/bin/out/nested/hello.md",
+			"myapp/greeting.txt",
+		}, got)
+	})
+
+	t.Run("places the contents of roots mapped to an empty destination at the top of the archive", func(t *testing.T) {
+		got := archivedNames(t, []string{helloDirectoryFixture}, ArchiverRootNames(map[string]string{helloDirectoryFixture: ""}))
+		assert.Equal(t, []string{"hello.txt", "nested/", "nested/hello.md"}, got)
+	})
+
+	t.Run("renames and skips entries using the rename function, after filtering", func(t *testing.T) {
+		got := archivedNames(t, []string{helloDirectoryFixture}, ArchiverExclude("hello/hello.txt"), ArchiverPrefix("myapp"),
+			ArchiverRename(func(path string) (string, bool) {
+				if path == "myapp/hello/" {
+					return "", true
+				}
+				return strings.Replace(path, "/nested", "", 1), false
+			}))
+		assert.Equal(t, []string{"myapp/hello/", "myapp/hello/hello.md"}, got)
+	})
+
+	t.Run("passes the names of directories to the rename function with a slash", func(t *testing.T) {
+		got := archivedNames(t, []string{helloDirectoryFixture}, ArchiverRename(func(path string) (string, bool) {
+			if path == "hello/nested/" {
+				return "hello/renamed/", false
+			}
+			return path, strings.HasSuffix(path, "/")
+		}))
+		assert.Equal(t, []string{"hello/hello.txt", "hello/nested/hello.md", "hello/renamed/"}, got)
+	})
+
+	t.Run("renames entries of reproducible archives before sorting them", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "archive.zip")
+		createArchive(t, path, []string{helloDirectoryFixture}, ArchiverReproducible(time.Time{}),
+			ArchiverRename(func(path string) (string, bool) {
+				return strings.Replace(path, "hello/nested", "a", 1), false
+			}))
+
+		archiveReader := testutils.GetArchiveReader(t, path)
+		defer archiveReader.Close()
+
+		var got []string
+		for _, file := range archiveReader.File {
+			got = append(got, file.Name)
+		}
+		assert.Equal(t, []string{"a/", "a/hello.md", "hello/", "hello/hello.txt"}, got)
+	})
+
+	t.Run("maps the root of a file system", func(t *testing.T) {
+		fsys := fstest.MapFS{"hello.txt": {Data: []byte("hello")}, "nested/hello.md": {Data: []byte("hello")}}
+
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+		archiver, err := NewArchiver(archive, ArchiverRootNames(map[string]string{".": "site"}))
+		assert.NoError(t, err)
+		assert.NoError(t, archiver.ArchiveFS(context.Background(), fsys, "."))
+		assert.NoError(t, archiver.Close())
+
+		archiveReader := testutils.GetArchiveReader(t, archive.Name())
+		defer archiveReader.Close()
+
+		var got []string
+		for _, file := range archiveReader.File {
+			got = append(got, file.Name)
+		}
+		sort.Strings(got)
+		assert.Equal(t, []string{"site/hello.txt", "site/nested/", "site/nested/hello.md"}, got)
+	})
+
+	t.Run("returns an error for names outside of the archive", func(t *testing.T) {
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		_, err := NewArchiver(archive, ArchiverPrefix("../myapp"))
+		assert.Error(t, err)
+		_, err = NewArchiver(archive, ArchiverRootNames(map[string]string{helloDirectoryFixture: "/bin"}))
+		assert.Error(t, err)
+	})
+}
+
+// archivedNames archives files with the given options, returning the sorted names of the entries of the archive.
+func archivedNames(t testing.TB, files []string, options ...archiverOption) []string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "archive.zip")
+	createArchive(t, path, files, options...)
+
+	archiveReader := testutils.GetArchiveReader(t, path)
+	defer archiveReader.Close()
+
+	var names []string
+	for _, file := range archiveReader.File {
+		names = append(names, file.Name)
+	}
+	sort.Strings(names)
+	return names
+}
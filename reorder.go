@@ -0,0 +1,74 @@
+package pzip
+
+import (
+	"sync"
+
+	"github.com/ybirader/pzip/pool"
+)
+
+// A reorderBuffer sits in front of the file writer pool, holding compressed files until the files enqueued
+// before them have been written, so that entries are written in the order in which files are enqueued for
+// compression rather than the order in which their compression finishes. To bound the memory held by
+// compressed files, files which are too far ahead of the next file to write wait to be added.
+type reorderBuffer struct {
+	mu       sync.Mutex
+	advanced *sync.Cond
+	pending  map[int]*pool.File // compressed files waiting for the files before them, by sequence number
+	next     int                // sequence number of the next file to write
+	last     int                // sequence number given to the last file enqueued
+	window   int                // number of files, from the next file to write, that can be held
+	write    func(file *pool.File)
+}
+
+// newReorderBuffer returns a reorder buffer passing files in order to write, holding up to window files.
+func newReorderBuffer(window int, write func(file *pool.File)) *reorderBuffer {
+	r := &reorderBuffer{pending: make(map[int]*pool.File), last: -1, window: window, write: write}
+	r.advanced = sync.NewCond(&r.mu)
+	return r
+}
+
+// sequence gives file the next sequence number. Files must be sequenced in the order in which they're
+// enqueued for compression.
+func (r *reorderBuffer) sequence(file *pool.File) {
+	r.last++
+	file.Sequence = r.last
+}
+
+// add adds the compressed file to the buffer, writing it along with any held files which follow it
+// if the files before it have been written. If file is too far ahead of the next file to write, add
+// waits until it can be held. As files are compressed in the order in which they're sequenced, the
+// file being waited for is already being compressed, so it's never held up itself.
+func (r *reorderBuffer) add(file *pool.File) {
+	r.hold(file.Sequence, file)
+}
+
+// discard skips the file with the given sequence number, which failed to compress, so that the files
+// after it aren't held indefinitely.
+func (r *reorderBuffer) discard(sequence int) {
+	r.hold(sequence, nil)
+}
+
+func (r *reorderBuffer) hold(sequence int, file *pool.File) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for sequence >= r.next+r.window {
+		r.advanced.Wait()
+	}
+
+	r.pending[sequence] = file
+	for {
+		next, ok := r.pending[r.next]
+		if !ok {
+			break
+		}
+
+		delete(r.pending, r.next)
+		r.next++
+		if next != nil {
+			r.write(next)
+		}
+	}
+
+	r.advanced.Broadcast()
+}
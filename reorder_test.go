@@ -0,0 +1,131 @@
+package pzip
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/ybirader/pzip/internal/testutils"
+	"github.com/ybirader/pzip/pool"
+)
+
+func TestReorderBuffer(t *testing.T) {
+	t.Run("writes files in the order in which they're sequenced", func(t *testing.T) {
+		var written []int
+		r := newReorderBuffer(4, func(file *pool.File) {
+			written = append(written, file.Sequence)
+		})
+
+		files := make([]*pool.File, 10)
+		for i := range files {
+			files[i] = &pool.File{}
+			r.sequence(files[i])
+		}
+
+		var wg sync.WaitGroup
+		for i := len(files) - 1; i >= 0; i-- {
+			wg.Add(1)
+			go func(file *pool.File) {
+				defer wg.Done()
+				r.add(file)
+			}(files[i])
+		}
+		wg.Wait()
+
+		assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, written)
+		assert.Zero(t, len(r.pending))
+	})
+
+	t.Run("holds files until the files before them are added or discarded", func(t *testing.T) {
+		var written []int
+		r := newReorderBuffer(4, func(file *pool.File) {
+			written = append(written, file.Sequence)
+		})
+
+		files := make([]*pool.File, 3)
+		for i := range files {
+			files[i] = &pool.File{}
+			r.sequence(files[i])
+		}
+
+		r.add(files[2])
+		assert.Zero(t, len(written))
+
+		r.discard(files[0].Sequence)
+		assert.Zero(t, len(written))
+
+		r.add(files[1])
+		assert.Equal(t, []int{1, 2}, written)
+	})
+}
+
+func TestArchiverOrdered(t *testing.T) {
+	t.Run("writes entries in the order in which files are given", func(t *testing.T) {
+		dir := t.TempDir()
+		names := []string{"mimetype", "z.xhtml", "large.txt", "b.css", "a.opf"}
+		for _, name := range names {
+			contents := []byte(name)
+			if name == "large.txt" {
+				// compressing a large file takes longer than the files after it
+				contents = bytes.Repeat([]byte("large "), 1024*1024)
+			}
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, name), contents, 0644))
+		}
+
+		paths := make([]string, len(names))
+		for i, name := range names {
+			paths[i] = filepath.Join(dir, name)
+		}
+
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		archiver, err := NewArchiver(archive, ArchiverConcurrency(4), ArchiverOrdered())
+		assert.NoError(t, err)
+		assert.NoError(t, archiver.Archive(context.Background(), paths))
+		assert.NoError(t, archiver.Close())
+
+		archiveReader := testutils.GetArchiveReader(t, archive.Name())
+		defer archiveReader.Close()
+
+		var got []string
+		for _, file := range archiveReader.File {
+			got = append(got, file.Name)
+		}
+		assert.Equal(t, names, got)
+	})
+
+	t.Run("writes entries of directories in walk order", func(t *testing.T) {
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		archiver, err := NewArchiver(archive, ArchiverOrdered())
+		assert.NoError(t, err)
+		assert.NoError(t, archiver.Archive(context.Background(), []string{helloTxtFileFixture, helloDirectoryFixture}))
+		assert.NoError(t, archiver.Close())
+
+		archiveReader := testutils.GetArchiveReader(t, archive.Name())
+		defer archiveReader.Close()
+
+		var got []string
+		for _, file := range archiveReader.File {
+			got = append(got, file.Name)
+		}
+
+		var want []string
+		want = append(want, "hello.txt")
+		assert.NoError(t, filepath.Walk(helloDirectoryFixture, func(path string, info os.FileInfo, err error) error {
+			name, err := filepath.Rel(filepath.Dir(filepath.Clean(helloDirectoryFixture)), path)
+			if info.IsDir() {
+				name += "/"
+			}
+			want = append(want, filepath.ToSlash(name))
+			return err
+		}))
+		assert.Equal(t, want, got)
+	})
+}
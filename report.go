@@ -0,0 +1,104 @@
+package pzip
+
+import (
+	"archive/zip"
+	"strconv"
+	"time"
+
+	"github.com/ybirader/pzip/pool"
+)
+
+// A Report describes how the entries of an archive were written, along with totals for the whole archive,
+// so that compression ratios can be tracked over time. Reports are encoded to JSON as written by pzip -report.
+type Report struct {
+	Entries         []EntryReport `json:"entries,omitempty"`
+	EntryCount      int           `json:"entryCount"`
+	Size            int64         `json:"size"`           // uncompressed size of all entries
+	CompressedSize  int64         `json:"compressedSize"` // compressed size of all entries, excluding headers
+	Ratio           float64       `json:"ratio"`
+	CompressionTime time.Duration `json:"compressionTime"` // time spent compressing entries, summed across goroutines
+	Duration        time.Duration `json:"duration"`        // time spent archiving, from the start of Archive until Close
+	Overflowed      int           `json:"overflowed"`      // entries whose compressed contents overflowed to disk
+	Copied          int           `json:"copied"`          // entries copied from an updated archive
+}
+
+// An EntryReport describes how an entry was written. Durations are encoded to JSON in nanoseconds.
+type EntryReport struct {
+	Name            string        `json:"name"`
+	Size            int64         `json:"size"`
+	CompressedSize  int64         `json:"compressedSize"`
+	Method          string        `json:"method"`          // compression method, such as deflate, or its number if it has no name
+	Ratio           float64       `json:"ratio"`           // compressed size as a fraction of the size, or 1 for empty entries
+	CompressionTime time.Duration `json:"compressionTime"` // time spent compressing the entry, including reading it
+	Overflowed      bool          `json:"overflowed"`      // whether the compressed contents overflowed the in-memory buffer to disk
+	Copied          bool          `json:"copied"`          // whether the entry was copied from an updated archive without compressing it
+}
+
+// add adds the entry described by header to the report, listing it among the entries if entries are reported.
+func (r *Report) add(header *zip.FileHeader, compressionTime time.Duration, overflowed, copied, listed bool) {
+	entry := EntryReport{
+		Name:            header.Name,
+		Size:            int64(header.UncompressedSize64),
+		CompressedSize:  int64(header.CompressedSize64),
+		Method:          methodName(header),
+		Ratio:           ratio(header.CompressedSize64, header.UncompressedSize64),
+		CompressionTime: compressionTime,
+		Overflowed:      overflowed,
+		Copied:          copied,
+	}
+
+	r.EntryCount++
+	r.Size += entry.Size
+	r.CompressedSize += entry.CompressedSize
+	r.Ratio = ratio(uint64(r.CompressedSize), uint64(r.Size))
+	r.CompressionTime += compressionTime
+	if overflowed {
+		r.Overflowed++
+	}
+	if copied {
+		r.Copied++
+	}
+
+	if listed {
+		r.Entries = append(r.Entries, entry)
+	}
+}
+
+// addFile adds the entry written for file to the report.
+func (r *Report) addFile(file *pool.File, listed bool) {
+	if file.Source != nil {
+		r.add(&file.Source.FileHeader, 0, false, true, listed)
+		return
+	}
+
+	r.add(file.Header, file.Elapsed, file.Overflowed(), false, listed)
+}
+
+// ratio returns compressed as a fraction of size, which is 1 for empty entries.
+func ratio(compressed, size uint64) float64 {
+	if size == 0 {
+		return 1
+	}
+
+	return float64(compressed) / float64(size)
+}
+
+// methodName returns the name of the method used to compress the contents of the entry described by header,
+// as accepted by ArchiverCLI, or its number if it has no name. The method of entries encrypted with AES is
+// stored in their AES extra field.
+func methodName(header *zip.FileHeader) string {
+	method := header.Method
+	if method == aesMethod {
+		if _, _, compressionMethod, ok := parseAESExtraField(header.Extra); ok {
+			method = compressionMethod
+		}
+	}
+
+	for name, m := range methods {
+		if m == method {
+			return name
+		}
+	}
+
+	return strconv.Itoa(int(method))
+}
@@ -0,0 +1,94 @@
+package pzip
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/ybirader/pzip/internal/testutils"
+	"github.com/ybirader/pzip/pool"
+)
+
+func TestArchiverReport(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"small.txt": strings.Repeat("small ", 100),
+		"large.txt": strings.Repeat("l", pool.DefaultBufferSize+1),
+	})
+
+	t.Run("reports every entry and totals for the archive", func(t *testing.T) {
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		archiver, err := NewArchiver(archive, ArchiverMethod(zip.Store), ArchiverReport())
+		assert.NoError(t, err)
+		assert.NoError(t, archiver.Archive(context.Background(), []string{filepath.Join(dir, "small.txt"), filepath.Join(dir, "large.txt")}))
+		assert.NoError(t, archiver.Close())
+
+		report := archiver.Report()
+		assert.Equal(t, 2, report.EntryCount)
+		assert.Equal(t, 2, len(report.Entries))
+		assert.Equal(t, int64(600+pool.DefaultBufferSize+1), report.Size)
+		assert.Equal(t, report.Size, report.CompressedSize)
+		assert.Equal(t, 1.0, report.Ratio)
+		assert.Equal(t, 1, report.Overflowed)
+		assertGreaterThan(t, int64(report.Duration), int64(report.CompressionTime)/int64(report.EntryCount))
+
+		for _, entry := range report.Entries {
+			assert.Equal(t, "store", entry.Method)
+			assert.Equal(t, entry.Name == "large.txt", entry.Overflowed, entry.Name)
+			assertGreaterThan(t, int64(entry.CompressionTime), 0)
+		}
+	})
+
+	t.Run("only reports totals unless configured to list entries", func(t *testing.T) {
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		archiver, err := NewArchiver(archive)
+		assert.NoError(t, err)
+		assert.NoError(t, archiver.Archive(context.Background(), []string{filepath.Join(dir, "small.txt")}))
+		assert.NoError(t, archiver.Close())
+
+		report := archiver.Report()
+		assert.Equal(t, 1, report.EntryCount)
+		assert.Zero(t, len(report.Entries))
+		assert.True(t, report.Ratio < 0.1)
+	})
+
+	t.Run("reports entries copied from an updated archive", func(t *testing.T) {
+		existingPath := filepath.Join(t.TempDir(), "existing.zip")
+		createArchive(t, existingPath, []string{dir})
+
+		updated, err := os.Create(filepath.Join(t.TempDir(), "updated.zip"))
+		assert.NoError(t, err)
+		defer updated.Close()
+
+		archiver, err := NewArchiver(updated, ArchiverUpdate(existingPath), ArchiverReport())
+		assert.NoError(t, err)
+		assert.NoError(t, archiver.Archive(context.Background(), []string{dir}))
+		assert.NoError(t, archiver.Close())
+
+		report := archiver.Report()
+		assert.Equal(t, 3, report.Copied)
+		for _, entry := range report.Entries {
+			assert.True(t, entry.Copied, entry.Name)
+		}
+	})
+
+	t.Run("encodes reports to JSON", func(t *testing.T) {
+		report := Report{}
+		report.add(&zip.FileHeader{Name: "hello.txt", Method: Zstd, UncompressedSize64: 100, CompressedSize64: 25}, 0, false, false, true)
+
+		encoded, err := json.Marshal(report)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"entries":[{"name":"hello.txt","size":100,"compressedSize":25,"method":"zstd","ratio":0.25,`+
+			`"compressionTime":0,"overflowed":false,"copied":false}],"entryCount":1,"size":100,"compressedSize":25,`+
+			`"ratio":0.25,"compressionTime":0,"duration":0,"overflowed":0,"copied":0}`, string(encoded))
+	})
+}
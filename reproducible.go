@@ -0,0 +1,93 @@
+package pzip
+
+import (
+	"archive/zip"
+	"fmt"
+	"io/fs"
+	"sort"
+	"time"
+
+	"github.com/ybirader/pzip/pool"
+)
+
+// DefaultSourceDate is the time to which modification times of reproducible archives are clamped when no
+// source date is given. It's the earliest time that can be stored as an MS-DOS time.
+var DefaultSourceDate = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// A collectedFile is a file to be archived in a reproducible archive. Files are collected while walking
+// and archived once all of them are found, so that entries are written sorted by name.
+type collectedFile struct {
+	name       string // name of the entry, with a trailing slash for directories
+	path       string
+	info       fs.FileInfo
+	relativeTo string
+	reader     *pool.File // a file added with AddReader
+}
+
+// collect collects file, which is archived once all files have been found. Files read from the file
+// system are returned to the pool in the meantime, so that collecting many files doesn't hold their buffers.
+func (a *archiver) collect(file *pool.File, relativeTo string) {
+	name := entryName(file)
+	if file.Reader != nil {
+		a.collected = append(a.collected, collectedFile{name: name, reader: file})
+		return
+	}
+
+	a.collected = append(a.collected, collectedFile{name: name, path: file.Path, info: file.Info, relativeTo: relativeTo})
+	pool.FilePool.Put(file)
+}
+
+// archiveCollected enqueues the collected files for archiving, sorted by the names of their entries.
+func (a *archiver) archiveCollected() error {
+	collected := a.collected
+	a.collected = nil
+
+	sort.SliceStable(collected, func(i, j int) bool {
+		return collected[i].name < collected[j].name
+	})
+
+	for _, c := range collected {
+		if c.reader != nil {
+			a.enqueueFile(c.reader)
+			continue
+		}
+
+		file, err := pool.NewFile(c.path, c.info, c.relativeTo)
+		if err != nil {
+			return fmt.Errorf("new file %q: %w", c.path, err)
+		}
+		// the name may have been transformed when the file was collected
+		file.Header.Name = c.name
+		a.enqueueFile(file)
+	}
+
+	return nil
+}
+
+// normalize clamps the modification time of header to the source date and normalizes its permissions,
+// so that archives of the same files are identical regardless of when, where and by whom they're created.
+// Files are readable by everyone and only writable by their owner, keeping whether they're executable.
+func (a *archiver) normalize(header *zip.FileHeader) {
+	if header.Modified.After(a.sourceDate) {
+		header.Modified = a.sourceDate
+	}
+
+	// the MS-DOS time is stored in UTC rather than the local time zone
+	modified := header.Modified.UTC()
+	header.Modified = modified
+	if modified.Before(DefaultSourceDate) {
+		modified = DefaultSourceDate
+	}
+	header.ModifiedDate = uint16(modified.Day() + int(modified.Month())<<5 + (modified.Year()-1980)<<9)
+	header.ModifiedTime = uint16(modified.Second()/2 + modified.Minute()<<5 + modified.Hour()<<11)
+
+	mode := header.Mode()
+	perm := fs.FileMode(0644)
+	switch {
+	case mode&fs.ModeSymlink != 0:
+		perm = 0777
+	case mode.IsDir() || mode&0111 != 0:
+		perm = 0755
+	}
+	header.SetMode(mode.Type() | perm)
+}
@@ -0,0 +1,144 @@
+package pzip
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/ybirader/pzip/internal/testutils"
+)
+
+func TestArchiverReproducible(t *testing.T) {
+	t.Run("writes identical archives regardless of concurrency and modification times", func(t *testing.T) {
+		dir := createReproducibleTree(t)
+
+		first := filepath.Join(t.TempDir(), "first.zip")
+		createReproducibleArchive(t, first, dir, 1)
+
+		now := time.Now()
+		assert.NoError(t, filepath.Walk(dir, func(path string, info fs.FileInfo, err error) error {
+			assert.NoError(t, err)
+			return os.Chtimes(path, now, now)
+		}))
+
+		second := filepath.Join(t.TempDir(), "second.zip")
+		createReproducibleArchive(t, second, dir, 8)
+
+		firstContents, err := os.ReadFile(first)
+		assert.NoError(t, err)
+		secondContents, err := os.ReadFile(second)
+		assert.NoError(t, err)
+		assert.True(t, bytes.Equal(firstContents, secondContents))
+	})
+
+	t.Run("writes entries sorted by name", func(t *testing.T) {
+		dir := createReproducibleTree(t)
+
+		path := filepath.Join(t.TempDir(), "archive.zip")
+		createArchive(t, path, []string{dir}, ArchiverReproducible(time.Time{}))
+
+		archiveReader := testutils.GetArchiveReader(t, path)
+		defer archiveReader.Close()
+
+		var names []string
+		for _, file := range archiveReader.File {
+			names = append(names, file.Name)
+		}
+		assert.True(t, sort.StringsAreSorted(names), names)
+		assert.Equal(t, 2+30+3, len(names))
+	})
+
+	t.Run("clamps modification times and normalizes permissions", func(t *testing.T) {
+		dir := createReproducibleTree(t)
+		sourceDate := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+		old := time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
+		assert.NoError(t, os.Chtimes(filepath.Join(dir, "old.txt"), old, old))
+
+		path := filepath.Join(t.TempDir(), "archive.zip")
+		createArchive(t, path, []string{dir}, ArchiverReproducible(sourceDate))
+
+		archiveReader := testutils.GetArchiveReader(t, path)
+		defer archiveReader.Close()
+
+		for _, file := range archiveReader.File {
+			wantModified, wantPerm := sourceDate, fs.FileMode(0644)
+			if file.Name == "tree/old.txt" {
+				wantModified = old
+			}
+			if file.Name == "tree/run.sh" || file.FileInfo().IsDir() {
+				wantPerm = 0755
+			}
+
+			assert.True(t, file.Modified.Equal(wantModified), file.Name)
+			assert.Equal(t, wantPerm, file.Mode().Perm(), file.Name)
+		}
+	})
+
+	t.Run("returns an error when encrypting", func(t *testing.T) {
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		_, err := NewArchiver(archive, ArchiverReproducible(time.Time{}), ArchiverEncryption(testPassword, 256))
+		assert.Error(t, err)
+	})
+
+	t.Run("returns an error when updating or syncing", func(t *testing.T) {
+		existingPath := filepath.Join(t.TempDir(), "existing.zip")
+		createArchive(t, existingPath, []string{helloTxtFileFixture})
+
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		_, err := NewArchiver(archive, ArchiverReproducible(time.Time{}), ArchiverUpdate(existingPath))
+		assert.Error(t, err)
+
+		_, err = NewArchiver(archive, ArchiverSync(existingPath), ArchiverReproducible(time.Time{}))
+		assert.Error(t, err)
+	})
+}
+
+// createReproducibleArchive archives dir to a reproducible archive at path using the given concurrency, compressing
+// files of at least two blocks in blocks.
+func createReproducibleArchive(t testing.TB, path string, dir string, concurrency int) {
+	t.Helper()
+
+	archive, err := os.Create(path)
+	assert.NoError(t, err)
+	defer archive.Close()
+
+	archiver, err := NewArchiver(archive, ArchiverConcurrency(concurrency), ArchiverReproducible(time.Time{}))
+	assert.NoError(t, err)
+	archiver.parallelThreshold = 2 * defaultBlockSize
+	assert.NoError(t, archiver.Archive(context.Background(), []string{dir}))
+	assert.NoError(t, archiver.Close())
+}
+
+// createReproducibleTree returns a directory of many small files, a file large enough to be compressed in blocks,
+// an executable and an old file, with permissions that differ from those of reproducible archives.
+func createReproducibleTree(t testing.TB) string {
+	t.Helper()
+
+	dir := filepath.Join(t.TempDir(), "tree")
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "nested"), 0700))
+
+	for i := 0; i < 30; i++ {
+		name := filepath.Join(dir, "nested", string(rune('a'+i%26))+string(rune('0'+i/26))+".txt")
+		assert.NoError(t, os.WriteFile(name, bytes.Repeat([]byte(name), 100), 0600))
+	}
+
+	large := make([]byte, 3*defaultBlockSize)
+	_, err := rand.Read(large[:len(large)/2])
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "large.bin"), large, 0664))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "run.sh"), []byte("#!/bin/sh\n"), 0700))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "old.txt"), []byte("old\n"), 0600))
+
+	return dir
+}
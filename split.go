@@ -0,0 +1,489 @@
+package pzip
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zip"
+)
+
+// A split archive is made up of volumes (disks) of at most a fixed size, named archive.z01, archive.z02 and so on,
+// with the last volume named archive.zip (See 8.5 https://pkware.cachefly.net/webdocs/casestudies/APPNOTE.TXT).
+// The first volume starts with the split signature, or with the temporary spanning marker if the archive fits in
+// a single volume. Local file headers and the records of the central directory don't span volumes.
+const (
+	splitSignature    = 0x08074b50
+	spanningMarker    = 0x30304b50
+	splitSignatureLen = 4
+)
+
+// MinVolumeSize is the minimum size of the volumes of a split archive, as for Info-ZIP.
+const MinVolumeSize int64 = 64 * 1024
+
+// A SplitWriter writes an archive as a split archive of volumes of at most a fixed size. Pass it to NewArchiver to
+// write a split archive; the archiver places local file headers and the central directory so that they don't span
+// volumes, and records the volume on which each entry starts. Close() should be called on the returned writer once
+// the archiver is closed.
+type SplitWriter struct {
+	path       string
+	base       string // absolute path of the archive without its extension, which volumes are named after
+	volumeSize int64
+	volume     *os.File
+	volumes    []string // paths of the volumes written so far
+	starts     []int64  // offsets within the archive at which each volume starts
+	written    int64    // bytes written to the current volume
+	offset     int64    // bytes of the archive written so far, excluding the split signature
+	directory  *bytes.Buffer
+}
+
+// NewSplitWriter returns a writer of a split archive whose last volume is at path, with volumes of at most
+// volumeSize bytes. It returns an error if volumeSize is smaller than MinVolumeSize.
+func NewSplitWriter(path string, volumeSize int64) (*SplitWriter, error) {
+	if volumeSize < MinVolumeSize {
+		return nil, fmt.Errorf("volume size %d is smaller than %d", volumeSize, MinVolumeSize)
+	}
+
+	absolutePath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("absolute path %q: %w", path, err)
+	}
+
+	s := &SplitWriter{path: path, base: volumeBase(absolutePath), volumeSize: volumeSize}
+	if err = s.next(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// volumeBase returns the path of the archive at path without its .zip extension.
+func volumeBase(path string) string {
+	if strings.EqualFold(filepath.Ext(path), ".zip") {
+		return path[:len(path)-len(".zip")]
+	}
+
+	return path
+}
+
+// volumePath returns the path of the volume numbered disk, other than the last, of the archive with the given base.
+func volumePath(base string, disk int) string {
+	return fmt.Sprintf("%s.z%02d", base, disk+1)
+}
+
+// Name returns the path of the last volume, so that the archive isn't archived itself.
+func (s *SplitWriter) Name() string {
+	return s.path
+}
+
+// isVolume reports whether the file at the absolute path is one of the volumes being written.
+func (s *SplitWriter) isVolume(path string) bool {
+	suffix, ok := strings.CutPrefix(path, s.base+".z")
+	if !ok || len(suffix) < 2 {
+		return false
+	}
+
+	_, err := strconv.Atoi(suffix)
+	return err == nil
+}
+
+func (s *SplitWriter) Write(p []byte) (int, error) {
+	if s.directory != nil {
+		return s.directory.Write(p)
+	}
+
+	var n int
+	for len(p) > 0 {
+		if s.written == s.volumeSize {
+			if err := s.next(); err != nil {
+				return n, err
+			}
+		}
+
+		chunk := p[:min(int64(len(p)), s.volumeSize-s.written)]
+		written, err := s.volume.Write(chunk)
+		n += written
+		s.written += int64(written)
+		s.offset += int64(written)
+		if err != nil {
+			return n, fmt.Errorf("write volume %q: %w", s.volume.Name(), err)
+		}
+		p = p[written:]
+	}
+
+	return n, nil
+}
+
+// reserve starts a new volume unless the next n bytes fit in the current one.
+func (s *SplitWriter) reserve(n int64) error {
+	if s.written+n <= s.volumeSize || s.offset == s.starts[len(s.starts)-1] {
+		return nil
+	}
+
+	return s.next()
+}
+
+// next finishes the current volume, if any, and starts the next one. The first volume starts with the
+// temporary spanning marker, which is replaced by the split signature once there is a second volume.
+func (s *SplitWriter) next() error {
+	disk := len(s.volumes)
+	if s.volume != nil {
+		if disk == 1 {
+			signature := binary.LittleEndian.AppendUint32(nil, splitSignature)
+			if _, err := s.volume.WriteAt(signature, 0); err != nil {
+				return fmt.Errorf("write split signature: %w", err)
+			}
+		}
+
+		if err := s.volume.Close(); err != nil {
+			return fmt.Errorf("close volume %q: %w", s.volume.Name(), err)
+		}
+	}
+
+	volume, err := os.Create(volumePath(s.base, disk))
+	if err != nil {
+		return fmt.Errorf("create volume: %w", err)
+	}
+
+	s.volume = volume
+	s.volumes = append(s.volumes, volume.Name())
+	s.starts = append(s.starts, s.offset)
+	s.written = 0
+
+	if disk == 0 {
+		marker := binary.LittleEndian.AppendUint32(nil, spanningMarker)
+		if _, err = volume.Write(marker); err != nil {
+			return fmt.Errorf("write spanning marker: %w", err)
+		}
+		s.written = splitSignatureLen
+	}
+
+	return nil
+}
+
+// disk returns the number of the current volume.
+func (s *SplitWriter) disk() uint32 {
+	return uint32(len(s.volumes) - 1)
+}
+
+// position returns the volume and the offset within it of offset, an offset within the archive.
+func (s *SplitWriter) position(offset int64) (uint32, uint64) {
+	disk := sort.Search(len(s.starts), func(i int) bool {
+		return s.starts[i] > offset
+	}) - 1
+
+	position := offset - s.starts[disk]
+	if disk == 0 {
+		position += splitSignatureLen
+	}
+
+	return uint32(disk), uint64(position)
+}
+
+// captureDirectory makes subsequent writes, which are the central directory written by a zip.Writer,
+// be captured rather than written to the volumes.
+func (s *SplitWriter) captureDirectory() {
+	s.directory = &bytes.Buffer{}
+}
+
+// writeDirectory writes the captured central directory, in which offsets of local file headers are offsets within
+// the archive, to the volumes. Offsets are made relative to the volumes on which the headers start, and the end of
+// central directory records are replaced by ones for a split archive.
+func (s *SplitWriter) writeDirectory() error {
+	captured := s.directory.Bytes()
+	s.directory = nil
+
+	capturedEnd, err := readDirectoryEnd(bytes.NewReader(captured), int64(len(captured)))
+	if err != nil {
+		return err
+	}
+	end := &directoryEnd{disk: s.disk(), comment: capturedEnd.comment}
+	end.dirDisk, end.offset = s.position(s.offset)
+
+	r := bytes.NewReader(captured)
+	for {
+		header, err := readDirectoryHeader(r)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("read central directory: %w", err)
+		}
+
+		header.disk, header.offset = s.position(int64(header.offset))
+		record := header.encode()
+		if err = s.reserve(int64(len(record))); err != nil {
+			return err
+		}
+
+		if end.records == 0 {
+			end.dirDisk, end.offset = s.position(s.offset)
+		}
+		if s.disk() != end.disk {
+			end.disk, end.diskRecords = s.disk(), 0
+		}
+		end.diskRecords++
+		end.records++
+		end.size += uint64(len(record))
+
+		if _, err = s.Write(record); err != nil {
+			return fmt.Errorf("write central directory: %w", err)
+		}
+	}
+
+	// the end of central directory records are written together, so they are found on the last volume
+	end.zip64 = end.needsZip64()
+	if err = s.reserve(int64(len(end.encode()))); err != nil {
+		return err
+	}
+	if s.disk() != end.disk {
+		end.disk, end.diskRecords = s.disk(), 0
+	}
+	end.disks = s.disk() + 1
+	end.zip64Disk, end.zip64Offset = s.position(s.offset)
+
+	if _, err = s.Write(end.encode()); err != nil {
+		return fmt.Errorf("write end of central directory: %w", err)
+	}
+
+	return nil
+}
+
+// Close finishes writing the archive, naming the last volume after the archive. The archiver writing
+// to s must be closed first.
+func (s *SplitWriter) Close() error {
+	if err := s.volume.Close(); err != nil {
+		return fmt.Errorf("close volume %q: %w", s.volume.Name(), err)
+	}
+
+	last := s.volumes[len(s.volumes)-1]
+	if err := os.Rename(last, s.path); err != nil {
+		return fmt.Errorf("rename volume %q: %w", last, err)
+	}
+
+	return nil
+}
+
+// remove removes the volumes written so far, after archiving failed.
+func (s *SplitWriter) remove() error {
+	s.volume.Close()
+
+	var errs []error
+	for _, volume := range append(s.volumes, s.path) {
+		if err := os.Remove(volume); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// openArchive opens the archive at path for reading. If it's the last volume of a split archive, the other
+// volumes are opened as well and read as a single archive.
+func openArchive(path string) (*zip.Reader, io.Closer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	end, err := readDirectoryEnd(f, info.Size())
+	if err != nil || end.disks <= 1 && end.disk == 0 {
+		// not a split archive, any malformed directory is reported by zip.NewReader
+		r, err := zip.NewReader(f, info.Size())
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return r, f, nil
+	}
+
+	volumes, err := openVolumes(path, f, info.Size(), end)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	r, err := volumes.reader(end)
+	if err != nil {
+		volumes.Close()
+		return nil, nil, err
+	}
+
+	return r, volumes, nil
+}
+
+// volumeSet holds the volumes of a split archive.
+type volumeSet struct {
+	files   []*os.File
+	volumes multiReaderAt
+}
+
+// openVolumes opens the volumes of the split archive whose last volume, last, is at path.
+func openVolumes(path string, last *os.File, lastSize int64, end *directoryEnd) (*volumeSet, error) {
+	disks := int(end.disk) + 1
+	if end.zip64 {
+		disks = int(end.disks)
+	}
+
+	v := &volumeSet{}
+	base := volumeBase(path)
+	for disk := 0; disk < disks-1; disk++ {
+		f, err := os.Open(volumePath(base, disk))
+		if err != nil {
+			v.Close()
+			return nil, fmt.Errorf("open volume: %w", err)
+		}
+		v.files = append(v.files, f)
+
+		info, err := f.Stat()
+		if err != nil {
+			v.Close()
+			return nil, fmt.Errorf("stat volume %q: %w", f.Name(), err)
+		}
+		v.volumes.add(f, info.Size())
+	}
+	v.files = append(v.files, last)
+	v.volumes.add(last, lastSize)
+
+	return v, nil
+}
+
+// at returns a reader of the volumes starting at offset within the volume numbered disk.
+func (v *volumeSet) at(disk uint32, offset uint64) (io.Reader, error) {
+	if int(disk) >= len(v.files) {
+		return nil, fmt.Errorf("missing volume %d", disk+1)
+	}
+
+	start := v.volumes.offsets[disk] + int64(offset)
+	return io.NewSectionReader(&v.volumes, start, v.volumes.size-start), nil
+}
+
+// reader returns a reader of the split archive as a single archive, of which the central directory ends with end.
+// The contents of the volumes before the central directory are read as is, followed by a central directory
+// in which offsets of local file headers are made relative to the start of the first volume.
+func (v *volumeSet) reader(end *directoryEnd) (*zip.Reader, error) {
+	if end.zip64 {
+		r, err := v.at(end.zip64Disk, end.zip64Offset)
+		if err != nil {
+			return nil, err
+		}
+		if err = end.readZip64(r); err != nil {
+			return nil, err
+		}
+	}
+
+	r, err := v.at(end.dirDisk, end.offset)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(io.LimitReader(r, int64(end.size)))
+
+	var directory []byte
+	for i := uint64(0); i < end.records; i++ {
+		header, err := readDirectoryHeader(br)
+		if err == io.EOF {
+			return nil, errDirectoryFormat
+		} else if err != nil {
+			return nil, fmt.Errorf("read central directory: %w", err)
+		}
+
+		if header.disk > end.dirDisk {
+			return nil, errDirectoryFormat
+		}
+		header.offset += uint64(v.volumes.offsets[header.disk])
+		header.disk = 0
+		directory = append(directory, header.encode()...)
+	}
+
+	// the contents of the archive end where the central directory starts
+	contentsSize := v.volumes.offsets[end.dirDisk] + int64(end.offset)
+	joined := &directoryEnd{
+		diskRecords: end.records,
+		records:     end.records,
+		size:        uint64(len(directory)),
+		offset:      uint64(contentsSize),
+		zip64Offset: uint64(contentsSize) + uint64(len(directory)),
+		disks:       1,
+		comment:     end.comment,
+	}
+	joined.zip64 = joined.needsZip64()
+	directory = append(directory, joined.encode()...)
+
+	archive := &multiReaderAt{}
+	archive.add(io.NewSectionReader(&v.volumes, 0, contentsSize), contentsSize)
+	archive.add(bytes.NewReader(directory), int64(len(directory)))
+
+	return zip.NewReader(archive, archive.size)
+}
+
+func (v *volumeSet) Close() error {
+	var errs []error
+	for _, f := range v.files {
+		errs = append(errs, f.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+// A multiReaderAt reads from the concatenation of readers.
+type multiReaderAt struct {
+	readers []io.ReaderAt
+	offsets []int64 // offset of each reader within the concatenation
+	size    int64
+}
+
+// add appends r, of the given size, to the concatenation.
+func (m *multiReaderAt) add(r io.ReaderAt, size int64) {
+	m.readers = append(m.readers, r)
+	m.offsets = append(m.offsets, m.size)
+	m.size += size
+}
+
+func (m *multiReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+
+	var n int
+	for len(p) > 0 {
+		if off >= m.size {
+			return n, io.EOF
+		}
+
+		// the last reader starting at or before off, skipping empty readers
+		i := sort.Search(len(m.offsets), func(i int) bool {
+			return m.offsets[i] > off
+		}) - 1
+		end := m.size
+		if i+1 < len(m.offsets) {
+			end = m.offsets[i+1]
+		}
+
+		chunk := p[:min(int64(len(p)), end-off)]
+		read, err := m.readers[i].ReadAt(chunk, off-m.offsets[i])
+		n += read
+		off += int64(read)
+		p = p[read:]
+		if read < len(chunk) {
+			if err == nil || err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return n, err
+		}
+	}
+
+	return n, nil
+}
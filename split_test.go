@@ -0,0 +1,152 @@
+package pzip
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/ybirader/pzip/internal/testutils"
+)
+
+func TestArchiverSplit(t *testing.T) {
+	t.Run("splits an archive into volumes which are extracted as a single archive", func(t *testing.T) {
+		dir := t.TempDir()
+		// random contents don't compress, so the archive spans several volumes
+		for _, name := range []string{"a.bin", "b.bin", "c.bin"} {
+			contents := make([]byte, 100*1024)
+			_, err := rand.Read(contents)
+			assert.NoError(t, err)
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, name), contents, 0644))
+		}
+
+		path := filepath.Join(t.TempDir(), "split.zip")
+		createSplitArchive(t, path, dir)
+
+		volumes, err := filepath.Glob(filepath.Join(filepath.Dir(path), "split.z*"))
+		assert.NoError(t, err)
+		assert.Equal(t, 5, len(volumes))
+		for _, volume := range volumes {
+			info, err := os.Stat(volume)
+			assert.NoError(t, err)
+			assert.True(t, info.Size() <= MinVolumeSize)
+		}
+		assert.Equal(t, uint32(splitSignature), readSignature(t, volumes[0], 0))
+
+		outputDir := extractArchive(t, path)
+		for _, name := range []string{"a.bin", "b.bin", "c.bin"} {
+			want, err := os.ReadFile(filepath.Join(dir, name))
+			assert.NoError(t, err)
+			got, err := os.ReadFile(filepath.Join(outputDir, filepath.Base(dir), name))
+			assert.NoError(t, err)
+			assert.Equal(t, want, got)
+		}
+	})
+
+	t.Run("records the volumes on which local file headers start", func(t *testing.T) {
+		dir := t.TempDir()
+		for i := 0; i < 200; i++ {
+			contents := make([]byte, 1024)
+			_, err := rand.Read(contents)
+			assert.NoError(t, err)
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, filepath.Base(t.TempDir())+".bin"), contents, 0644))
+		}
+
+		path := filepath.Join(t.TempDir(), "split.zip")
+		createSplitArchive(t, path, dir)
+
+		f, err := os.Open(path)
+		assert.NoError(t, err)
+		defer f.Close()
+		info, err := f.Stat()
+		assert.NoError(t, err)
+
+		end, err := readDirectoryEnd(f, info.Size())
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(3), end.disk)
+		assert.Equal(t, uint64(201), end.records)
+
+		volumes, err := openVolumes(path, f, info.Size(), end)
+		assert.NoError(t, err)
+		defer volumes.Close()
+		r, err := volumes.at(end.dirDisk, end.offset)
+		assert.NoError(t, err)
+
+		base := volumeBase(path)
+		for i := uint64(0); i < end.records; i++ {
+			header, err := readDirectoryHeader(r)
+			assert.NoError(t, err)
+
+			volume := path
+			if header.disk < end.disk {
+				volume = volumePath(base, int(header.disk))
+			}
+			assert.Equal(t, uint32(0x04034b50), readSignature(t, volume, int64(header.offset)))
+			assert.True(t, int64(header.offset)+fileHeaderLen+int64(len(header.name)) <= MinVolumeSize)
+		}
+	})
+
+	t.Run("writes a single volume with the spanning marker", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "split.zip")
+		createSplitArchive(t, path, helloDirectoryFixture)
+
+		volumes, err := filepath.Glob(filepath.Join(filepath.Dir(path), "split.z*"))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{path}, volumes)
+		assert.Equal(t, uint32(spanningMarker), readSignature(t, path, 0))
+
+		archiveReader := testutils.GetArchiveReader(t, path)
+		defer archiveReader.Close()
+		assert.Equal(t, 4, len(archiveReader.File))
+	})
+
+	t.Run("returns an error for a volume size smaller than the minimum", func(t *testing.T) {
+		_, err := NewSplitWriter(filepath.Join(t.TempDir(), "split.zip"), MinVolumeSize-1)
+		assert.Error(t, err)
+	})
+
+	t.Run("returns an error when updating a split archive", func(t *testing.T) {
+		dir := t.TempDir()
+		existing := filepath.Join(dir, "existing.zip")
+		createArchive(t, existing, []string{helloTxtFileFixture})
+
+		archive, err := NewSplitWriter(filepath.Join(dir, "split.zip"), MinVolumeSize)
+		assert.NoError(t, err)
+		defer archive.remove()
+
+		_, err = NewArchiver(archive, ArchiverUpdate(existing))
+		assert.Error(t, err)
+	})
+}
+
+// createSplitArchive archives the files at root to a split archive at path, with volumes of the minimum size.
+func createSplitArchive(t testing.TB, path string, root string) {
+	t.Helper()
+
+	archive, err := NewSplitWriter(path, MinVolumeSize)
+	assert.NoError(t, err)
+
+	archiver, err := NewArchiver(archive)
+	assert.NoError(t, err)
+	assert.NoError(t, archiver.Archive(context.Background(), []string{root}))
+	assert.NoError(t, archiver.Close())
+	assert.NoError(t, archive.Close())
+}
+
+// readSignature returns the signature at offset of the file at path.
+func readSignature(t testing.TB, path string, offset int64) uint32 {
+	t.Helper()
+
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	signature := make([]byte, 4)
+	_, err = io.ReadFull(io.NewSectionReader(f, offset, 4), signature)
+	assert.NoError(t, err)
+	return binary.LittleEndian.Uint32(signature)
+}
@@ -0,0 +1,101 @@
+package pzip
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/flate"
+	"github.com/ybirader/pzip/pool"
+)
+
+const (
+	sampleSize          = 64 * 1024
+	incompressibleRatio = 0.95
+)
+
+// DefaultStoredExtensions are the extensions of files whose contents are usually already compressed.
+var DefaultStoredExtensions = []string{
+	".7z", ".apk", ".avif", ".br", ".bz2", ".docx", ".epub", ".flac", ".gif", ".gz", ".heic", ".jar",
+	".jpeg", ".jpg", ".lz4", ".lzma", ".m4a", ".m4v", ".mkv", ".mov", ".mp3", ".mp4", ".odt", ".ogg",
+	".png", ".pptx", ".rar", ".tgz", ".txz", ".webm", ".webp", ".whl", ".xlsx", ".xz", ".zip", ".zst",
+}
+
+var samplerPool = sync.Pool{
+	New: func() any {
+		s := &sampler{}
+		s.compressor, _ = flate.NewWriter(&s.written, flate.BestSpeed)
+		return s
+	},
+}
+
+// A sampler measures how well the start of a file compresses.
+type sampler struct {
+	compressor *flate.Writer
+	written    countingWriter
+}
+
+// countingWriter counts the bytes written to it and discards them.
+type countingWriter int64
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	*c += countingWriter(len(p))
+	return len(p), nil
+}
+
+// methodFor returns the method used to compress file. Files with a stored extension are stored, as are files
+// whose sampled contents barely compress when sampling is enabled. The decision is made before compressing,
+// so files are only read once.
+func (a *archiver) methodFor(file *pool.File) (uint16, error) {
+	if a.method == zip.Store {
+		return a.method, nil
+	}
+
+	if a.storedExtensions[strings.ToLower(filepath.Ext(file.Path))] {
+		return zip.Store, nil
+	}
+
+	if !a.sampleCompressed || file.Reader != nil {
+		// readers can't be sampled without consuming them
+		return a.method, nil
+	}
+
+	compressible, err := a.compressible(file)
+	if err != nil {
+		return 0, fmt.Errorf("sample %q: %w", file.Path, err)
+	}
+	if !compressible {
+		return zip.Store, nil
+	}
+
+	return a.method, nil
+}
+
+// compressible reports whether the first sampleSize bytes of file compress to less than
+// incompressibleRatio of their size.
+func (a *archiver) compressible(file *pool.File) (bool, error) {
+	f, err := a.openFile(file)
+	if err != nil {
+		return false, fmt.Errorf("open %q: %w", file.Path, err)
+	}
+	defer f.Close()
+
+	s := samplerPool.Get().(*sampler)
+	defer samplerPool.Put(s)
+	s.written = 0
+	s.compressor.Reset(&s.written)
+
+	n, err := io.Copy(s.compressor, io.LimitReader(f, sampleSize))
+	if err != nil {
+		return false, fmt.Errorf("compress sample of %q: %w", file.Path, err)
+	}
+
+	if err = s.compressor.Close(); err != nil {
+		return false, fmt.Errorf("close sample compressor for %q: %w", file.Path, err)
+	}
+
+	return float64(s.written) < incompressibleRatio*float64(n), nil
+}
@@ -0,0 +1,131 @@
+package pzip
+
+import (
+	"archive/zip"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/ybirader/pzip/internal/testutils"
+	"github.com/ybirader/pzip/pool"
+)
+
+func TestStoreCompressed(t *testing.T) {
+	t.Run("stores files with a stored extension", func(t *testing.T) {
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		archiver, err := NewArchiver(archive, ArchiverStoreCompressed())
+		assert.NoError(t, err)
+
+		info := testutils.GetFileInfo(t, testArchiveFixture)
+		file, err := pool.NewFile(testArchiveFixture, info, "")
+		assert.NoError(t, err)
+
+		err = archiver.compress(file)
+		assert.NoError(t, err)
+
+		assert.Equal(t, zip.Store, file.Header.Method)
+		assert.Equal(t, uint64(info.Size()), file.Header.CompressedSize64)
+	})
+
+	t.Run("stores files whose contents don't compress", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "random.bin")
+		contents := make([]byte, 2*sampleSize)
+		_, err := rand.Read(contents)
+		assert.NoError(t, err)
+		err = os.WriteFile(filePath, contents, 0644)
+		assert.NoError(t, err)
+
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		archiver, err := NewArchiver(archive, ArchiverSampleCompressed())
+		assert.NoError(t, err)
+
+		info := testutils.GetFileInfo(t, filePath)
+		file, err := pool.NewFile(filePath, info, "")
+		assert.NoError(t, err)
+
+		err = archiver.compress(file)
+		assert.NoError(t, err)
+
+		assert.Equal(t, zip.Store, file.Header.Method)
+		assert.Equal(t, uint64(len(contents)), file.Header.CompressedSize64)
+	})
+
+	t.Run("only samples files when sampling is enabled", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "random.bin")
+		contents := make([]byte, 2*sampleSize)
+		_, err := rand.Read(contents)
+		assert.NoError(t, err)
+		err = os.WriteFile(filePath, contents, 0644)
+		assert.NoError(t, err)
+
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		archiver, err := NewArchiver(archive, ArchiverStoreCompressed())
+		assert.NoError(t, err)
+
+		info := testutils.GetFileInfo(t, filePath)
+		file, err := pool.NewFile(filePath, info, "")
+		assert.NoError(t, err)
+
+		err = archiver.compress(file)
+		assert.NoError(t, err)
+
+		assert.Equal(t, zip.Deflate, file.Header.Method)
+	})
+
+	t.Run("stores small files which compressing would make larger", func(t *testing.T) {
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		archiver, err := NewArchiver(archive, ArchiverSampleCompressed())
+		assert.NoError(t, err)
+
+		info := testutils.GetFileInfo(t, helloTxtFileFixture)
+		file, err := pool.NewFile(helloTxtFileFixture, info, "")
+		assert.NoError(t, err)
+
+		err = archiver.compress(file)
+		assert.NoError(t, err)
+
+		assert.Equal(t, zip.Store, file.Header.Method)
+		assert.Equal(t, uint64(info.Size()), file.Header.CompressedSize64)
+		assert.Equal(t, int64(info.Size()), file.Written())
+	})
+
+	t.Run("compresses files whose contents compress", func(t *testing.T) {
+		filePath, _ := createLargeFile(t, 2*sampleSize)
+
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		archiver, err := NewArchiver(archive, ArchiverSampleCompressed())
+		assert.NoError(t, err)
+
+		info := testutils.GetFileInfo(t, filePath)
+		file, err := pool.NewFile(filePath, info, "")
+		assert.NoError(t, err)
+
+		err = archiver.compress(file)
+		assert.NoError(t, err)
+
+		assert.Equal(t, zip.Deflate, file.Header.Method)
+		assertGreaterThan(t, int64(info.Size()), int64(file.Header.CompressedSize64))
+	})
+
+	t.Run("matches extensions regardless of case", func(t *testing.T) {
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		archiver, err := NewArchiver(archive, ArchiverStoreCompressed("ZIP"))
+		assert.NoError(t, err)
+
+		assert.Equal(t, map[string]bool{".zip": true}, archiver.storedExtensions)
+	})
+}
@@ -0,0 +1,18 @@
+package pzip
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// fileTimes returns the access and creation times of the file described by info. They're zero if
+// they aren't known, such as for files which aren't read from the operating system's file system.
+func fileTimes(info fs.FileInfo) (accessed, created time.Time) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}, time.Time{}
+	}
+
+	return time.Unix(stat.Atimespec.Unix()), time.Unix(stat.Birthtimespec.Unix())
+}
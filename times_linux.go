@@ -0,0 +1,18 @@
+package pzip
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// fileTimes returns the access time of the file described by info. Linux doesn't report creation times
+// through stat, so the creation time is always zero.
+func fileTimes(info fs.FileInfo) (accessed, created time.Time) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}, time.Time{}
+	}
+
+	return time.Unix(stat.Atim.Unix()), time.Time{}
+}
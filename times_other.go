@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !windows
+
+package pzip
+
+import (
+	"io/fs"
+	"time"
+)
+
+// fileTimes returns zero times, as access and creation times aren't read on this operating system.
+func fileTimes(info fs.FileInfo) (accessed, created time.Time) {
+	return time.Time{}, time.Time{}
+}
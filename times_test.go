@@ -0,0 +1,224 @@
+package pzip
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	kzip "github.com/klauspost/compress/zip"
+	"github.com/ybirader/pzip/internal/testutils"
+)
+
+func TestNTFSExtraField(t *testing.T) {
+	t.Run("parses an encoded field among other fields, keeping 100ns precision", func(t *testing.T) {
+		modified := time.Date(2040, 3, 4, 5, 6, 7, 123456700, time.UTC)
+		accessed := time.Date(1970, 1, 1, 0, 0, 0, 100, time.UTC)
+
+		extra := NewExtendedTimestampExtraField(modified).Encode()
+		extra = append(extra, NewNTFSExtraField(modified, accessed, time.Time{}).Encode()...)
+
+		got, ok := ParseNTFSExtraField(extra)
+		assert.True(t, ok)
+		assert.True(t, got.Modified.Equal(modified), got.Modified.String())
+		assert.True(t, got.Accessed.Equal(accessed), got.Accessed.String())
+		assert.True(t, got.Created.IsZero())
+	})
+
+	t.Run("clamps times before the Windows epoch", func(t *testing.T) {
+		assert.Equal(t, uint64(1), toFileTime(time.Date(1600, 12, 31, 0, 0, 0, 0, time.UTC)))
+		assert.Equal(t, uint64(1), toFileTime(time.Date(1601, 1, 1, 0, 0, 0, 0, time.UTC)))
+		assert.Equal(t, uint64(10), toFileTime(time.Date(1601, 1, 1, 0, 0, 0, 1000, time.UTC)))
+	})
+
+	t.Run("returns false without a field or for fields without times", func(t *testing.T) {
+		_, ok := ParseNTFSExtraField(NewExtendedTimestampExtraField(time.Now()).Encode())
+		assert.False(t, ok)
+
+		extra := []byte{0x0a, 0x00, 8, 0, 0, 0, 0, 0, 2, 0, 0, 0}
+		_, ok = ParseNTFSExtraField(extra)
+		assert.False(t, ok)
+	})
+}
+
+func TestExtendedTimestampExtraField(t *testing.T) {
+	t.Run("parses the times which are set", func(t *testing.T) {
+		modified := time.Unix(1700000000, 0)
+		created := time.Unix(1600000000, 0)
+
+		got, ok := ParseExtendedTimestampExtraField(NewExtendedTimestampExtraField(modified).WithCreationTime(created).Encode())
+		assert.True(t, ok)
+		assert.True(t, got.Modified().Equal(modified))
+		assert.True(t, got.Accessed().IsZero())
+		assert.True(t, got.Created().Equal(created))
+	})
+
+	t.Run("encodes the modification time only for central directory headers", func(t *testing.T) {
+		modified := time.Unix(0x656553f1, 0)
+		field := NewExtendedTimestampExtraField(modified).WithAccessTime(time.Unix(1, 0)).WithCreationTime(time.Unix(2, 0))
+
+		assert.Equal(t, []byte{0x55, 0x54, 5, 0, 7, 0xf1, 0x53, 0x65, 0x65}, field.EncodeCentral())
+
+		withoutModified := NewExtendedTimestampExtraField(time.Time{}).WithAccessTime(time.Unix(1, 0))
+		assert.Equal(t, []byte{0x55, 0x54, 1, 0, 2}, withoutModified.EncodeCentral())
+
+		extra := append([]byte{0xcd, 0xab, 1, 0, 0xff}, field.Encode()...)
+		assert.Equal(t, append([]byte{0xcd, 0xab, 1, 0, 0xff}, field.EncodeCentral()...), centralExtra(extra))
+	})
+
+	t.Run("parses fields of central directory headers which only hold the modification time", func(t *testing.T) {
+		extra := []byte{0x55, 0x54, 5, 0, 3, 0xf1, 0x53, 0x65, 0x65}
+
+		got, ok := ParseExtendedTimestampExtraField(extra)
+		assert.True(t, ok)
+		assert.True(t, got.Modified().Equal(time.Unix(0x656553f1, 0)))
+		assert.True(t, got.Accessed().IsZero())
+	})
+}
+
+func TestArchiverFileTimes(t *testing.T) {
+	t.Run("stores modification times with sub-second precision", func(t *testing.T) {
+		dir := t.TempDir()
+		name := filepath.Join(dir, "precise.txt")
+		modified := time.Date(2021, 2, 3, 4, 5, 6, 789000000, time.UTC)
+		assert.NoError(t, os.WriteFile(name, []byte("precise"), 0644))
+		assert.NoError(t, os.Chtimes(name, modified, modified))
+
+		path := filepath.Join(t.TempDir(), "archive.zip")
+		createArchive(t, path, []string{name}, ArchiverFileTimes())
+
+		archiveReader := testutils.GetArchiveReader(t, path)
+		defer archiveReader.Close()
+
+		ntfs, ok := ParseNTFSExtraField(archiveReader.File[0].Extra)
+		assert.True(t, ok)
+		assert.True(t, ntfs.Modified.Equal(modified), ntfs.Modified.String())
+	})
+
+	t.Run("only stores extended timestamps by default", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "archive.zip")
+		createArchive(t, path, []string{helloTxtFileFixture})
+
+		archiveReader := testutils.GetArchiveReader(t, path)
+		defer archiveReader.Close()
+
+		_, ok := ParseNTFSExtraField(archiveReader.File[0].Extra)
+		assert.False(t, ok)
+		_, ok = ParseNTFSExtraField(localExtra(t, path))
+		assert.False(t, ok)
+		_, ok = ParseExtendedTimestampExtraField(archiveReader.File[0].Extra)
+		assert.True(t, ok)
+	})
+
+	t.Run("stores access times if configured to", func(t *testing.T) {
+		dir := t.TempDir()
+		name := filepath.Join(dir, "accessed.txt")
+		accessed := time.Date(2022, 1, 1, 0, 0, 0, 500, time.UTC)
+		assert.NoError(t, os.WriteFile(name, []byte("accessed"), 0644))
+		assert.NoError(t, os.Chtimes(name, accessed, time.Now()))
+
+		path := filepath.Join(t.TempDir(), "archive.zip")
+		createArchive(t, path, []string{name}, ArchiverFileTimes())
+
+		archiveReader := testutils.GetArchiveReader(t, path)
+		defer archiveReader.Close()
+
+		ntfs, ok := ParseNTFSExtraField(archiveReader.File[0].Extra)
+		assert.True(t, ok)
+		assert.True(t, ntfs.Accessed.Equal(accessed), ntfs.Accessed.String())
+
+		timestamp, ok := ParseExtendedTimestampExtraField(localExtra(t, path))
+		assert.True(t, ok)
+		assert.True(t, timestamp.Accessed().Equal(accessed.Truncate(time.Second)))
+	})
+
+	t.Run("only stores the modification time in the central directory's extended timestamp", func(t *testing.T) {
+		dir := t.TempDir()
+		name := filepath.Join(dir, "accessed.txt")
+		modified := time.Unix(1700000000, 0)
+		assert.NoError(t, os.WriteFile(name, []byte("accessed"), 0644))
+		assert.NoError(t, os.Chtimes(name, time.Unix(1600000000, 0), modified))
+
+		path := filepath.Join(t.TempDir(), "archive.zip")
+		createArchive(t, path, []string{name}, ArchiverFileTimes())
+
+		archiveReader := testutils.GetArchiveReader(t, path)
+		defer archiveReader.Close()
+
+		data, ok := findExtraField(archiveReader.File[0].Extra, extendedTimestampTag)
+		assert.True(t, ok)
+		assert.Equal(t, 5, len(data))
+		local, ok := findExtraField(localExtra(t, path), extendedTimestampTag)
+		assert.True(t, ok)
+		assert.Equal(t, local[0], data[0])
+
+		timestamp, ok := ParseExtendedTimestampExtraField(archiveReader.File[0].Extra)
+		assert.True(t, ok)
+		assert.True(t, timestamp.Modified().Equal(modified))
+		assert.True(t, timestamp.Accessed().IsZero())
+	})
+}
+
+// TestZipWriterCentralExtra pins the behavior of zip.Writer which archive relies on to write different local and
+// central extra fields: the central directory is written from the header given to CreateRaw when closing.
+func TestZipWriterCentralExtra(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.zip")
+	archive, err := os.Create(path)
+	assert.NoError(t, err)
+	defer archive.Close()
+
+	local := NewExtendedTimestampExtraField(time.Unix(1700000000, 0)).WithAccessTime(time.Unix(1600000000, 0))
+	header := &kzip.FileHeader{Name: "hello.txt", Method: kzip.Store, Extra: local.Encode()}
+	w := kzip.NewWriter(archive)
+	_, err = w.CreateRaw(header)
+	assert.NoError(t, err)
+	header.Extra = centralExtra(header.Extra)
+	assert.NoError(t, w.Close())
+
+	archiveReader := testutils.GetArchiveReader(t, path)
+	defer archiveReader.Close()
+
+	assert.Equal(t, local.Encode(), localExtra(t, path))
+	assert.Equal(t, local.EncodeCentral(), archiveReader.File[0].Extra)
+}
+
+// localExtra returns the extra fields of the local file header of the first entry of the archive at path.
+func localExtra(t testing.TB, path string) []byte {
+	t.Helper()
+
+	contents, err := os.ReadFile(path)
+	assert.NoError(t, err)
+
+	nameLen := int(binary.LittleEndian.Uint16(contents[26:]))
+	extraLen := int(binary.LittleEndian.Uint16(contents[28:]))
+	return contents[30+nameLen : 30+nameLen+extraLen]
+}
+
+func TestExtractTimes(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "timed")
+	assert.NoError(t, os.Mkdir(dir, 0755))
+	name := filepath.Join(dir, "timed.txt")
+	assert.NoError(t, os.WriteFile(name, []byte("timed"), 0644))
+
+	fileModified := time.Date(2019, 5, 6, 7, 8, 9, 123456700, time.UTC)
+	dirModified := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.NoError(t, os.Chtimes(name, fileModified, fileModified))
+	assert.NoError(t, os.Chtimes(dir, dirModified, dirModified))
+
+	path := filepath.Join(t.TempDir(), "archive.zip")
+	createArchive(t, path, []string{dir}, ArchiverFileTimes())
+
+	t.Run("restores the modification times of files and directories", func(t *testing.T) {
+		outputDir := extractArchive(t, path)
+
+		info, err := os.Stat(filepath.Join(outputDir, "timed", "timed.txt"))
+		assert.NoError(t, err)
+		assert.True(t, info.ModTime().Equal(fileModified), info.ModTime().String())
+
+		info, err = os.Stat(filepath.Join(outputDir, "timed"))
+		assert.NoError(t, err)
+		assert.True(t, info.ModTime().Equal(dirModified), info.ModTime().String())
+	})
+}
@@ -0,0 +1,18 @@
+package pzip
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// fileTimes returns the access and creation times of the file described by info. They're zero if
+// they aren't known, such as for files which aren't read from the operating system's file system.
+func fileTimes(info fs.FileInfo) (accessed, created time.Time) {
+	attributes, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return time.Time{}, time.Time{}
+	}
+
+	return time.Unix(0, attributes.LastAccessTime.Nanoseconds()), time.Unix(0, attributes.CreationTime.Nanoseconds())
+}
@@ -0,0 +1,150 @@
+package pzip
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ybirader/pzip/pool"
+)
+
+// modTimeTolerance is the precision of the modification times of zip entries. Without an extended
+// timestamp, modification times are stored as MS-DOS times, which have a resolution of two seconds.
+const modTimeTolerance = 2 * time.Second
+
+// An updater keeps track of the entries of an existing archive that is being updated.
+type updater struct {
+	archive   *zip.ReadCloser
+	path      string               // absolute path of the existing archive
+	entries   map[string]*zip.File // entries that haven't been claimed, by name
+	sync      bool                 // whether entries that haven't been claimed are removed
+	encrypter encrypter            // encrypter of the updated archive, if it's encrypted
+	location  *time.Location       // location in which MS-DOS times of entries were written
+}
+
+// newUpdater opens the existing archive at path. When sync is set, the entries of files that
+// aren't archived are removed rather than kept.
+func newUpdater(path string, sync bool) (*updater, error) {
+	absolutePath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("absolute path %q: %w", path, err)
+	}
+
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("open archive %q: %w", path, err)
+	}
+
+	entries := make(map[string]*zip.File, len(archive.File))
+	for _, entry := range archive.File {
+		entries[entry.Name] = entry
+	}
+
+	return &updater{archive: archive, path: absolutePath, entries: entries, sync: sync, location: time.Local}, nil
+}
+
+// claim marks the existing entry with the name of file, if any, as replaced by file. The entry is returned
+// if file is unchanged since it was archived, so that the entry can be copied rather than compressing file.
+func (u *updater) claim(file *pool.File) *zip.File {
+	name := file.Header.Name
+	if file.Info.IsDir() && !strings.HasSuffix(name, "/") {
+		name += "/"
+	}
+
+	entry, ok := u.entries[name]
+	if !ok {
+		return nil
+	}
+	delete(u.entries, name)
+
+	if file.Reader != nil || !unchanged(entry, file.Info, u.location) || !u.copyable(entry) {
+		return nil
+	}
+
+	return entry
+}
+
+// remaining returns the entries that haven't been claimed and are kept, in the order of the existing archive.
+// Entries which can't be copied as is are removed.
+func (u *updater) remaining() []*zip.File {
+	if u.sync {
+		return nil
+	}
+
+	var entries []*zip.File
+	for _, entry := range u.archive.File {
+		if u.entries[entry.Name] == entry && u.copyable(entry) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+// copyable reports whether entry can be copied as is. When the updated archive is encrypted, the entries of
+// files must be encrypted in the same way, as copied entries aren't encrypted again. The contents of directories
+// and symbolic links aren't encrypted.
+func (u *updater) copyable(entry *zip.File) bool {
+	if u.encrypter == nil || entry.Mode().IsDir() || entry.Mode()&fs.ModeSymlink != 0 {
+		return true
+	}
+
+	return u.encrypter.encrypts(entry.Flags, entry.Method)
+}
+
+func (u *updater) close() error {
+	return u.archive.Close()
+}
+
+// unchanged reports whether the file described by info has the same type, size and modification time
+// as entry, whose MS-DOS time, if it only has one, is in location.
+func unchanged(entry *zip.File, info fs.FileInfo, location *time.Location) bool {
+	if entry.Mode().Type() != info.Mode().Type() {
+		return false
+	}
+
+	if !info.IsDir() && entry.UncompressedSize64 != uint64(info.Size()) {
+		return false
+	}
+
+	difference := info.ModTime().Sub(modifiedTime(entry, location))
+	return difference > -modTimeTolerance && difference < modTimeTolerance
+}
+
+// modifiedTime returns the modification time of entry. Entries without a timestamp extra field only have an MS-DOS
+// time, which is the time in location the entry was written in, but which is read as UTC, so its location is
+// corrected.
+func modifiedTime(entry *zip.File, location *time.Location) time.Time {
+	modified := entry.Modified
+	// the reader gives times from extra fields a location other than UTC when there's an MS-DOS time
+	if modified.Location() != time.UTC || (entry.ModifiedDate == 0 && entry.ModifiedTime == 0) {
+		return modified
+	}
+
+	return time.Date(modified.Year(), modified.Month(), modified.Day(), modified.Hour(), modified.Minute(),
+		modified.Second(), modified.Nanosecond(), location)
+}
+
+// copyEntry writes entry to w as is, without decompressing and compressing its contents again.
+func copyEntry(w *zip.Writer, entry *zip.File) error {
+	r, err := entry.OpenRaw()
+	if err != nil {
+		return fmt.Errorf("open raw %q: %w", entry.Name, err)
+	}
+
+	header := entry.FileHeader
+	fileWriter, err := w.CreateRaw(&header)
+	if err != nil {
+		return fmt.Errorf("create raw for %q: %w", entry.Name, err)
+	}
+
+	if _, err = io.Copy(fileWriter, r); err != nil {
+		return fmt.Errorf("copy %q: %w", entry.Name, err)
+	}
+
+	return nil
+}
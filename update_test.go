@@ -0,0 +1,215 @@
+package pzip
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/ybirader/pzip/internal/testutils"
+)
+
+func TestArchiverUpdate(t *testing.T) {
+	t.Run("copies unchanged entries, compresses modified files and keeps entries of missing files", func(t *testing.T) {
+		dirPath := t.TempDir()
+		writeFiles(t, dirPath, map[string]string{
+			"unchanged.txt": "unchanged",
+			"modified.txt":  "original",
+			"removed.txt":   "removed",
+		})
+		existingPath := filepath.Join(t.TempDir(), "existing.zip")
+		createArchive(t, existingPath, []string{dirPath})
+
+		writeFiles(t, dirPath, map[string]string{
+			"modified.txt": "modified contents",
+			"added.txt":    "added",
+		})
+		assert.NoError(t, os.Remove(filepath.Join(dirPath, "removed.txt")))
+
+		updatedPath := filepath.Join(t.TempDir(), "updated.zip")
+		createArchive(t, updatedPath, []string{dirPath}, ArchiverUpdate(existingPath), ArchiverMethod(zip.Store))
+
+		archiveReader := testutils.GetArchiveReader(t, updatedPath)
+		defer archiveReader.Close()
+
+		base := filepath.Base(dirPath)
+		assert.Equal(t, 5, len(archiveReader.File))
+		testutils.AssertArchiveContainsFile(t, archiveReader.File, base+"/")
+
+		// unchanged files keep the method they were compressed with, while modified and added files are stored
+		expected := map[string]struct {
+			contents string
+			method   uint16
+		}{
+			"unchanged.txt": {"unchanged", zip.Deflate},
+			"modified.txt":  {"modified contents", zip.Store},
+			"added.txt":     {"added", zip.Store},
+			"removed.txt":   {"removed", zip.Deflate},
+		}
+		for name, want := range expected {
+			file, found := testutils.Find(archiveReader.File, func(file *zip.File) bool {
+				return file.Name == base+"/"+name
+			})
+			assert.True(t, found, name)
+			assert.Equal(t, want.contents, readArchivedFile(t, file))
+			assert.Equal(t, want.method, file.Method, name)
+		}
+	})
+
+	t.Run("removes entries of missing files when syncing", func(t *testing.T) {
+		dirPath := t.TempDir()
+		writeFiles(t, dirPath, map[string]string{
+			"unchanged.txt": "unchanged",
+			"modified.txt":  "original",
+			"removed.txt":   "removed",
+		})
+		existingPath := filepath.Join(t.TempDir(), "existing.zip")
+		createArchive(t, existingPath, []string{dirPath})
+
+		writeFiles(t, dirPath, map[string]string{"modified.txt": "modified contents"})
+		assert.NoError(t, os.Remove(filepath.Join(dirPath, "removed.txt")))
+
+		syncedPath := filepath.Join(t.TempDir(), "synced.zip")
+		createArchive(t, syncedPath, []string{dirPath}, ArchiverSync(existingPath))
+
+		archiveReader := testutils.GetArchiveReader(t, syncedPath)
+		defer archiveReader.Close()
+
+		base := filepath.Base(dirPath)
+		assert.Equal(t, 3, len(archiveReader.File))
+		testutils.AssertArchiveContainsFile(t, archiveReader.File, base+"/")
+		testutils.AssertArchiveContainsFile(t, archiveReader.File, base+"/unchanged.txt")
+
+		modified, found := testutils.Find(archiveReader.File, func(file *zip.File) bool {
+			return file.Name == base+"/modified.txt"
+		})
+		assert.True(t, found)
+		assert.Equal(t, "modified contents", readArchivedFile(t, modified))
+	})
+
+	t.Run("encrypts unchanged entries and removes kept entries which aren't encrypted when encrypting", func(t *testing.T) {
+		dirPath := t.TempDir()
+		writeFiles(t, dirPath, map[string]string{
+			"unchanged.txt": "unchanged",
+			"removed.txt":   "removed",
+		})
+		existingPath := filepath.Join(t.TempDir(), "existing.zip")
+		createArchive(t, existingPath, []string{dirPath})
+		assert.NoError(t, os.Remove(filepath.Join(dirPath, "removed.txt")))
+
+		updatedPath := filepath.Join(t.TempDir(), "updated.zip")
+		createArchive(t, updatedPath, []string{dirPath}, ArchiverUpdate(existingPath), ArchiverEncryption(testPassword, 256))
+
+		err := extractArchiveErr(t, updatedPath)
+		assert.Error(t, err)
+
+		archiveReader := testutils.GetArchiveReader(t, updatedPath)
+		defer archiveReader.Close()
+
+		base := filepath.Base(dirPath)
+		assert.Equal(t, 2, len(archiveReader.File))
+		file, found := testutils.Find(archiveReader.File, func(file *zip.File) bool {
+			return file.Name == base+"/unchanged.txt"
+		})
+		assert.True(t, found)
+		assert.NotZero(t, file.Flags&encryptedFlag)
+
+		outputDir := extractArchive(t, updatedPath, ExtractorPassword(testPassword))
+		contents, err := os.ReadFile(filepath.Join(outputDir, base, "unchanged.txt"))
+		assert.NoError(t, err)
+		assert.Equal(t, "unchanged", string(contents))
+	})
+
+	t.Run("compares the MS-DOS times of entries without extended timestamps as local times", func(t *testing.T) {
+		location := time.FixedZone("UTC+5", 5*60*60)
+
+		dirPath := t.TempDir()
+		writeFiles(t, dirPath, map[string]string{"unchanged.txt": "unchanged"})
+		modified := time.Date(2020, 1, 2, 3, 4, 6, 0, location)
+		assert.NoError(t, os.Chtimes(filepath.Join(dirPath, "unchanged.txt"), modified, modified))
+
+		base := filepath.Base(dirPath)
+		existingPath := filepath.Join(t.TempDir(), "existing.zip")
+		createMSDOSArchive(t, existingPath, base+"/unchanged.txt", "unchanged", modified)
+
+		updatedPath := filepath.Join(t.TempDir(), "updated.zip")
+		archive, err := os.Create(updatedPath)
+		assert.NoError(t, err)
+		defer archive.Close()
+
+		archiver, err := NewArchiver(archive, ArchiverUpdate(existingPath), ArchiverMethod(zip.Store))
+		assert.NoError(t, err)
+		archiver.updater.location = location
+		assert.NoError(t, archiver.Archive(context.Background(), []string{dirPath}))
+		assert.NoError(t, archiver.Close())
+
+		archiveReader := testutils.GetArchiveReader(t, updatedPath)
+		defer archiveReader.Close()
+
+		file, found := testutils.Find(archiveReader.File, func(file *zip.File) bool {
+			return file.Name == base+"/unchanged.txt"
+		})
+		assert.True(t, found)
+		assert.Equal(t, zip.Deflate, file.Method)
+	})
+
+	t.Run("returns an error when writing to the archive being updated", func(t *testing.T) {
+		existingPath := filepath.Join(t.TempDir(), "existing.zip")
+		createArchive(t, existingPath, []string{helloTxtFileFixture})
+
+		archive, err := os.OpenFile(existingPath, os.O_WRONLY, 0)
+		assert.NoError(t, err)
+		defer archive.Close()
+
+		_, err = NewArchiver(archive, ArchiverUpdate(existingPath))
+		assert.Error(t, err)
+	})
+
+	t.Run("returns an error when the archive doesn't exist", func(t *testing.T) {
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		_, err := NewArchiver(archive, ArchiverUpdate(filepath.Join(t.TempDir(), "missing.zip")))
+		assert.Error(t, err)
+	})
+}
+
+func createArchive(t testing.TB, path string, files []string, options ...archiverOption) {
+	t.Helper()
+
+	archive, err := os.Create(path)
+	assert.NoError(t, err)
+	defer archive.Close()
+
+	archiver, err := NewArchiver(archive, options...)
+	assert.NoError(t, err)
+
+	assert.NoError(t, archiver.Archive(context.Background(), files))
+	assert.NoError(t, archiver.Close())
+}
+
+// createMSDOSArchive writes an archive to path with a single entry, which only has an MS-DOS modification time,
+// as written by tools which don't store extended timestamps.
+func createMSDOSArchive(t testing.TB, path, name, contents string, modified time.Time) {
+	t.Helper()
+
+	archive, err := os.Create(path)
+	assert.NoError(t, err)
+	defer archive.Close()
+
+	w := zip.NewWriter(archive)
+	header := &zip.FileHeader{
+		Name:         name,
+		Method:       zip.Deflate,
+		ModifiedDate: uint16((modified.Year()-1980)<<9 | int(modified.Month())<<5 | modified.Day()),
+		ModifiedTime: uint16(modified.Hour()<<11 | modified.Minute()<<5 | modified.Second()/2),
+	}
+	fw, err := w.CreateHeader(header)
+	assert.NoError(t, err)
+	_, err = fw.Write([]byte(contents))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+}
@@ -0,0 +1,157 @@
+package pzip
+
+import (
+	"crypto/rand"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/klauspost/compress/zip"
+	"github.com/ybirader/pzip/pool"
+)
+
+// ZipCrypto is the traditional PKWARE encryption of zip files (See 6.1 https://pkware.cachefly.net/webdocs/casestudies/APPNOTE.TXT).
+// It is weak, being vulnerable to known-plaintext attacks, and is only supported for interoperability with tools
+// which don't support AES encryption. The encrypted contents of an entry are preceded by an encrypted header, the
+// last byte of which is used to check the password.
+const (
+	zipCryptoHeaderSize = 12
+	dataDescriptorFlag  = 0x8
+)
+
+// zipCryptoKeys are the keys of the ZipCrypto cipher, which are updated with each byte of plaintext.
+type zipCryptoKeys [3]uint32
+
+func newZipCryptoKeys(password []byte) *zipCryptoKeys {
+	keys := &zipCryptoKeys{0x12345678, 0x23456789, 0x34567890}
+	for _, b := range password {
+		keys.update(b)
+	}
+
+	return keys
+}
+
+func (k *zipCryptoKeys) update(b byte) {
+	k[0] = crc32Update(k[0], b)
+	k[1] = (k[1]+k[0]&0xff)*134775813 + 1
+	k[2] = crc32Update(k[2], byte(k[1]>>24))
+}
+
+func (k *zipCryptoKeys) streamByte() byte {
+	temp := k[2] | 2
+	return byte((temp * (temp ^ 1)) >> 8)
+}
+
+func crc32Update(crc uint32, b byte) uint32 {
+	return crc32.IEEETable[byte(crc)^b] ^ crc>>8
+}
+
+// zipCryptoEncryptStream encrypts bytes using the ZipCrypto cipher.
+type zipCryptoEncryptStream struct {
+	keys *zipCryptoKeys
+}
+
+func (z *zipCryptoEncryptStream) XORKeyStream(dst, src []byte) {
+	for i, b := range src {
+		dst[i] = b ^ z.keys.streamByte()
+		z.keys.update(b)
+	}
+}
+
+// zipCryptoDecryptStream decrypts bytes using the ZipCrypto cipher.
+type zipCryptoDecryptStream struct {
+	keys *zipCryptoKeys
+}
+
+func (z *zipCryptoDecryptStream) XORKeyStream(dst, src []byte) {
+	for i, b := range src {
+		dst[i] = b ^ z.keys.streamByte()
+		z.keys.update(dst[i])
+	}
+}
+
+// A zipCryptoEncrypter encrypts files using ZipCrypto.
+type zipCryptoEncrypter struct {
+	password []byte
+}
+
+func (e *zipCryptoEncrypter) headerSize() int {
+	return zipCryptoHeaderSize
+}
+
+func (e *zipCryptoEncrypter) encrypts(flags, method uint16) bool {
+	return flags&encryptedFlag != 0 && method != aesMethod
+}
+
+// encrypt encrypts the compressed contents of file in place, filling in the reserved header with random bytes
+// followed by the high byte of the CRC-32 of file, which is used to check the password.
+func (e *zipCryptoEncrypter) encrypt(file *pool.File) error {
+	header, err := reservedHeader(file, zipCryptoHeaderSize)
+	if err != nil {
+		return err
+	}
+
+	if _, err = rand.Read(header[:zipCryptoHeaderSize-1]); err != nil {
+		return fmt.Errorf("generate header: %w", err)
+	}
+	header[zipCryptoHeaderSize-1] = byte(file.Header.CRC32 >> 24)
+
+	if err = encryptContents(file, 0, &zipCryptoEncryptStream{newZipCryptoKeys(e.password)}, io.Discard); err != nil {
+		return fmt.Errorf("encrypt contents: %w", err)
+	}
+
+	// without a data descriptor, readers check the password against the CRC-32 rather than the modification time
+	file.Header.Flags &^= dataDescriptorFlag
+	file.Header.Flags |= encryptedFlag
+
+	return nil
+}
+
+// openZipCrypto opens the contents of the ZipCrypto encrypted file, decrypting them with password. As the password
+// check only uses a single byte, an incorrect password may go undetected until the CRC-32 of the contents is checked.
+func openZipCrypto(file *zip.File, password []byte) (io.ReadCloser, error) {
+	if file.CompressedSize64 < zipCryptoHeaderSize {
+		return nil, zip.ErrFormat
+	}
+
+	raw, err := file.OpenRaw()
+	if err != nil {
+		return nil, fmt.Errorf("open raw: %w", err)
+	}
+
+	decrypter := &zipCryptoDecryptStream{newZipCryptoKeys(password)}
+
+	header := make([]byte, zipCryptoHeaderSize)
+	if _, err = io.ReadFull(raw, header); err != nil {
+		return nil, fmt.Errorf("read encryption header: %w", err)
+	}
+	decrypter.XORKeyStream(header, header)
+
+	check := byte(file.CRC32 >> 24)
+	if file.Flags&dataDescriptorFlag != 0 {
+		check = byte(file.ModifiedTime >> 8)
+	}
+	if header[zipCryptoHeaderSize-1] != check {
+		return nil, ErrPassword
+	}
+
+	r := &zipCryptoReader{r: io.LimitReader(raw, int64(file.CompressedSize64-zipCryptoHeaderSize)), decrypter: decrypter}
+	rc, err := decompress(r, file.Method)
+	if err != nil {
+		return nil, err
+	}
+
+	return &checksumReader{ReadCloser: rc, hash: crc32.NewIEEE(), want: file.CRC32}, nil
+}
+
+// zipCryptoReader decrypts the contents read from r.
+type zipCryptoReader struct {
+	r         io.Reader
+	decrypter *zipCryptoDecryptStream
+}
+
+func (z *zipCryptoReader) Read(p []byte) (int, error) {
+	n, err := z.r.Read(p)
+	z.decrypter.XORKeyStream(p[:n], p[:n])
+	return n, err
+}
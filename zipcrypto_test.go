@@ -0,0 +1,71 @@
+package pzip
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/ybirader/pzip/internal/testutils"
+)
+
+// zipCryptoArchiveFixture is an archive of hello.txt and streamed contents, encrypted by Info-ZIP with the password "secret".
+const zipCryptoArchiveFixture = testdataRoot + "zipcrypto.zip"
+
+func TestExtractZipCrypto(t *testing.T) {
+	t.Run("decrypts files encrypted by other tools", func(t *testing.T) {
+		outputDir := extractArchive(t, zipCryptoArchiveFixture, ExtractorPassword("secret"))
+
+		want, err := os.ReadFile(helloTxtFileFixture)
+		assert.NoError(t, err)
+		got, err := os.ReadFile(filepath.Join(outputDir, "hello.txt"))
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+
+		streamed, err := os.ReadFile(filepath.Join(outputDir, "-"))
+		assert.NoError(t, err)
+		assert.Equal(t, "streamed contents\n", string(streamed))
+	})
+
+	t.Run("returns an error for an incorrect password", func(t *testing.T) {
+		err := extractArchiveErr(t, zipCryptoArchiveFixture, ExtractorPassword("incorrect"))
+		assert.True(t, errors.Is(err, ErrPassword), err.Error())
+	})
+}
+
+func TestArchiverZipCrypto(t *testing.T) {
+	for _, method := range []uint16{zip.Store, zip.Deflate} {
+		t.Run(fmt.Sprintf("encrypts and decrypts files compressed using method %d", method), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "encrypted.zip")
+			createArchive(t, path, []string{helloDirectoryFixture}, ArchiverMethod(method), ArchiverZipCrypto("secret"))
+
+			archiveReader := testutils.GetArchiveReader(t, path)
+			hello, found := testutils.Find(archiveReader.File, func(file *zip.File) bool {
+				return file.Name == "hello/hello.txt"
+			})
+			assert.True(t, found)
+			assert.Equal(t, method, hello.Method)
+			assert.NotZero(t, hello.Flags&encryptedFlag)
+			archiveReader.Close()
+
+			outputDir := extractArchive(t, path, ExtractorPassword("secret"))
+
+			want, err := os.ReadFile(filepath.Join(helloDirectoryFixture, "hello.txt"))
+			assert.NoError(t, err)
+			got, err := os.ReadFile(filepath.Join(outputDir, "hello", "hello.txt"))
+			assert.NoError(t, err)
+			assert.Equal(t, want, got)
+		})
+	}
+
+	t.Run("returns an error for an empty password", func(t *testing.T) {
+		archive, cleanup := testutils.CreateTempArchive(t, archivePath)
+		defer cleanup()
+
+		_, err := NewArchiver(archive, ArchiverZipCrypto(""))
+		assert.Error(t, err)
+	})
+}